@@ -47,7 +47,6 @@ import (
 	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
-	modelstatus "istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	kubesecrets "istio.io/istio/pilot/pkg/secrets/kube"
 	securityModel "istio.io/istio/pilot/pkg/security/model"
@@ -60,6 +59,7 @@ import (
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -852,20 +852,10 @@ func (s *Server) initRegistryEventHandlers() {
 
 	if s.configController != nil {
 		configHandler := func(old config.Config, curr config.Config, event model.Event) {
-			if old.Generation == curr.Generation && curr.Generation != 0 {
-				// Kubernetes will start generation at 1, but some internally generated configurations
-				// may not set resource version at all and we still want updates from these
-				if curr.GroupVersionKind == gvk.WorkloadEntry {
-					oldCond := modelstatus.GetConditionFromSpec(old, modelstatus.ConditionHealthy)
-					newCond := modelstatus.GetConditionFromSpec(curr, modelstatus.ConditionHealthy)
-					if oldCond == newCond {
-						return
-					}
-				} else if onlyStatusUpdated(old, curr) {
-					log.Debugf("skipping push for %v/%v, due to no change in spec or labels\n",
-						old.Namespace, old.Name)
-					return
-				}
+			if skipConfigUpdatePush(old, curr) {
+				log.Debugf("skipping push for %v/%v, due to no change in spec or labels\n",
+					old.Namespace, old.Name)
+				return
 			}
 
 			pushReq := &model.PushRequest{
@@ -877,6 +867,32 @@ func (s *Server) initRegistryEventHandlers() {
 				}: {}},
 				Reason: []model.TriggerReason{model.ConfigUpdate},
 			}
+			if curr.GroupVersionKind == gvk.Gateway && event == model.EventUpdate {
+				pushReq.GatewayChange = model.ClassifyGatewayChange(old, curr)
+			}
+			if curr.GroupVersionKind == gvk.EnvoyFilter {
+				pushReq.EnvoyFilterTarget = model.EnvoyFilterTargetOf(old, curr)
+				pushReq.StatsOnly = model.EnvoyFilterStatsOnly(old, curr)
+			}
+			if curr.GroupVersionKind == gvk.VirtualService && event == model.EventUpdate {
+				pushReq.VirtualServiceChange = model.ClassifyVirtualServiceChange(old, curr)
+			}
+			if curr.GroupVersionKind == gvk.DestinationRule {
+				pushReq.DestinationRuleNamespaces = model.DestinationRuleExportedNamespaces(old, curr)
+				pushReq.DestinationRuleChange = model.ClassifyDestinationRuleChange(old, curr)
+				pushReq.DestinationRuleHosts = map[model.ConfigKey][]host.Name{{
+					Kind:      curr.GroupVersionKind,
+					Name:      curr.Name,
+					Namespace: curr.Namespace,
+				}: model.DestinationRuleHosts(old, curr)}
+			}
+			if event != model.EventDelete && curr.Generation > 0 {
+				pushReq.ConfigGenerations = map[model.ConfigKey]int64{{
+					Kind:      curr.GroupVersionKind,
+					Name:      curr.Name,
+					Namespace: curr.Namespace,
+				}: curr.Generation}
+			}
 			s.XDSServer.ConfigUpdate(pushReq)
 			if event != model.EventDelete {
 				s.statusReporter.AddInProgressResource(curr)
@@ -904,6 +920,23 @@ func (s *Server) initRegistryEventHandlers() {
 	}
 }
 
+// skipConfigUpdatePush returns true if an update of the config does not require a full push.
+func skipConfigUpdatePush(old config.Config, curr config.Config) bool {
+	// Kubernetes will start generation at 1, but some internally generated configurations
+	// may not set resource version at all and we still want updates from these
+	if old.Generation != curr.Generation || curr.Generation == 0 {
+		return false
+	}
+	if curr.GroupVersionKind == gvk.WorkloadEntry {
+		// If neither the spec nor the labels changed, at most the health status flipped. This only changes
+		// endpoint availability, which the service registries handle as an EDS update scoped to the proxies
+		// depending on the backing services. Annotations are not compared, as they are updated whenever the
+		// workload connects or disconnects.
+		return labels.Equals(old.Labels, curr.Labels) && reflect.DeepEqual(old.Spec, curr.Spec)
+	}
+	return onlyStatusUpdated(old, curr)
+}
+
 // onlyStatusUpdated returns false if changes are observed in labels, annotations, or spec, and otherwise returns true.
 func onlyStatusUpdated(old config.Config, curr config.Config) bool {
 	return labels.Equals(old.Labels, curr.Labels) &&
@@ -24,10 +24,15 @@ import (
 
 	. "github.com/onsi/gomega"
 
+	"istio.io/api/meta/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
+	modelstatus "istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/testcerts"
 	"istio.io/pkg/filewatcher"
 )
@@ -365,3 +370,61 @@ func checkCert(t *testing.T, s *Server, cert, key []byte) bool {
 	}
 	return bytes.Equal(actual.Certificate[0], expected.Certificate[0])
 }
+
+func TestSkipConfigUpdatePush(t *testing.T) {
+	healthStatus := func(healthy string) *v1alpha1.IstioStatus {
+		return &v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{{Type: modelstatus.ConditionHealthy, Status: healthy}}}
+	}
+	workloadEntry := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.WorkloadEntry,
+			Name:             "we",
+			Namespace:        "ns",
+			Generation:       1,
+		},
+		Spec:   &networking.WorkloadEntry{Address: "1.1.1.1"},
+		Status: healthStatus(modelstatus.StatusTrue),
+	}
+	unhealthy := workloadEntry.DeepCopy()
+	unhealthy.Status = healthStatus(modelstatus.StatusFalse)
+	moved := workloadEntry.DeepCopy()
+	moved.Generation = 2
+	moved.Spec = &networking.WorkloadEntry{Address: "2.2.2.2"}
+	relabeledEntry := workloadEntry.DeepCopy()
+	relabeledEntry.Labels = map[string]string{"app": "foo"}
+
+	serviceEntry := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.ServiceEntry,
+			Name:             "se",
+			Namespace:        "ns",
+			Generation:       1,
+		},
+		Spec: &networking.ServiceEntry{Hosts: []string{"foo.com"}},
+	}
+	relabeled := serviceEntry.DeepCopy()
+	relabeled.Labels = map[string]string{"foo": "bar"}
+	internal := serviceEntry.DeepCopy()
+	internal.Generation = 0
+
+	cases := []struct {
+		name string
+		old  config.Config
+		curr config.Config
+		want bool
+	}{
+		{"workload entry health flip", workloadEntry, unhealthy, true},
+		{"workload entry address change", workloadEntry, moved, false},
+		{"workload entry label change", workloadEntry, relabeledEntry, false},
+		{"status only update", serviceEntry, serviceEntry, true},
+		{"label update", serviceEntry, relabeled, false},
+		{"no generation", internal, internal, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipConfigUpdatePush(tt.old, tt.curr); got != tt.want {
+				t.Fatalf("got skip %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
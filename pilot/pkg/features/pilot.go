@@ -436,4 +436,12 @@ var (
 		20*time.Minute,
 		"The interval for istiod to fetch the jwks_uri for the jwks public key.",
 	).Get()
+
+	EnableZeroTargetPushMetric = env.RegisterBoolVar(
+		"PILOT_ENABLE_ZERO_TARGET_PUSH_METRIC",
+		false,
+		"If enabled, pilot will check which proxies a push reaches when starting it, and count the pushes "+
+			"reaching none of them in pilot_xds_zero_target_pushes. This is intended for tuning the config "+
+			"producers and checks every connected proxy an additional time for each push.",
+	).Get()
 )
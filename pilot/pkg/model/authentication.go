@@ -214,6 +214,35 @@ func (policy *AuthenticationPolicies) GetPeerAuthenticationsForWorkload(namespac
 	return getConfigsForWorkload(policy.peerAuthentications, policy.rootNamespace, namespace, workloadLabels)
 }
 
+// getPolicy returns the peer or request authentication policy with the name in the namespace, if any.
+func (policy *AuthenticationPolicies) getPolicy(kind config.GroupVersionKind, namespace, name string) *config.Config {
+	var configs []config.Config
+	switch kind {
+	case gvk.PeerAuthentication:
+		configs = policy.peerAuthentications[namespace]
+	case gvk.RequestAuthentication:
+		configs = policy.requestAuthentications[namespace]
+	}
+	for idx := range configs {
+		if cfg := &configs[idx]; cfg.Name == name {
+			return cfg
+		}
+	}
+	return nil
+}
+
+// policySelector returns the labels of the workloads the peer or request authentication policy selects, or nil
+// if it applies to the whole namespace.
+func policySelector(cfg *config.Config) map[string]string {
+	switch spec := cfg.Spec.(type) {
+	case *v1beta1.PeerAuthentication:
+		return spec.GetSelector().GetMatchLabels()
+	case *v1beta1.RequestAuthentication:
+		return spec.GetSelector().GetMatchLabels()
+	}
+	return nil
+}
+
 // GetRootNamespace return root namespace that is tracked by the policy object.
 func (policy *AuthenticationPolicies) GetRootNamespace() string {
 	return policy.rootNamespace
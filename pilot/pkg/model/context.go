@@ -205,6 +205,10 @@ type Proxy struct {
 	// Metadata key-value pairs extending the Node identifier
 	Metadata *NodeMetadata
 
+	// NamespaceLabels are the labels of the ConfigNamespace, as known to the service registries. They are nil
+	// if no registry knows the namespace.
+	NamespaceLabels map[string]string
+
 	// the sidecarScope associated with the proxy
 	SidecarScope *SidecarScope
 
@@ -662,7 +666,7 @@ const (
 // GetRouterMode returns the operating mode associated with the router.
 // Assumes that the proxy is of type Router
 func (node *Proxy) GetRouterMode() RouterMode {
-	if RouterMode(node.Metadata.RouterMode) == SniDnatRouter {
+	if node.Metadata != nil && RouterMode(node.Metadata.RouterMode) == SniDnatRouter {
 		return SniDnatRouter
 	}
 	return StandardRouter
@@ -700,6 +704,9 @@ func (node *Proxy) SetGatewaysForProxy(ps *PushContext) {
 		return
 	}
 	node.MergedGateway = ps.mergeGateways(node)
+	// Record the virtual services bound to the merged gateways, so that changes to virtual services
+	// that are not attached to this gateway, or not visible from its namespace, can be skipped.
+	node.SidecarScope.AddConfigDependencies(ps.GatewayVirtualServicesConfigKey(node)...)
 }
 
 func (node *Proxy) SetServiceInstances(serviceDiscovery ServiceDiscovery) {
@@ -734,6 +741,19 @@ func (node *Proxy) SetWorkloadLabels(env *Environment) {
 	}
 }
 
+// NamespaceLabelsGetter is implemented by service registries that know the labels of namespaces.
+type NamespaceLabelsGetter interface {
+	// GetProxyNamespaceLabels returns the labels of the proxy's namespace, or nil if they are unknown.
+	GetProxyNamespaceLabels(proxy *Proxy) map[string]string
+}
+
+// SetNamespaceLabels sets the labels of the proxy's namespace from the service registries, if they know them.
+func (node *Proxy) SetNamespaceLabels(env *Environment) {
+	if getter, ok := env.ServiceDiscovery.(NamespaceLabelsGetter); ok {
+		node.NamespaceLabels = getter.GetProxyNamespaceLabels(node)
+	}
+}
+
 // DiscoverIPVersions discovers the IP Versions supported by Proxy based on its IP addresses.
 func (node *Proxy) DiscoverIPVersions() {
 	for i := 0; i < len(node.IPAddresses); i++ {
@@ -949,7 +969,7 @@ const (
 // GetInterceptionMode extracts the interception mode associated with the proxy
 // from the proxy metadata
 func (node *Proxy) GetInterceptionMode() TrafficInterceptionMode {
-	if node == nil {
+	if node == nil || node.Metadata == nil {
 		return InterceptionRedirect
 	}
 
@@ -965,6 +985,23 @@ func (node *Proxy) GetInterceptionMode() TrafficInterceptionMode {
 	return InterceptionRedirect
 }
 
+// GetClusterID returns the ID of the cluster the proxy is in, or an empty ID if it is unknown, as it is
+// before the proxy's metadata is parsed.
+func (node *Proxy) GetClusterID() string {
+	if node == nil || node.Metadata == nil {
+		return ""
+	}
+	return node.Metadata.ClusterID
+}
+
+// GetNamespaceLabels returns the labels of the proxy's namespace, or nil if they are unknown.
+func (node *Proxy) GetNamespaceLabels() map[string]string {
+	if node == nil {
+		return nil
+	}
+	return node.NamespaceLabels
+}
+
 func (node *Proxy) IsVM() bool {
 	// TODO use node metadata to indicate that this is a VM intstead of the TestVMLabel
 	return node.Metadata != nil && node.Metadata.Labels[constants.TestVMLabel] != ""
@@ -610,3 +610,26 @@ func TestGlobalUnicastIP(t *testing.T) {
 		})
 	}
 }
+
+func TestProxyNilMetadata(t *testing.T) {
+	for _, node := range []*model.Proxy{nil, {Type: model.SidecarProxy}, {Type: model.Router}} {
+		if got := node.GetClusterID(); got != "" {
+			t.Errorf("GetClusterID() = %v, want empty", got)
+		}
+		if got := node.GetNamespaceLabels(); got != nil {
+			t.Errorf("GetNamespaceLabels() = %v, want nil", got)
+		}
+		if got := node.GetInterceptionMode(); got != model.InterceptionRedirect {
+			t.Errorf("GetInterceptionMode() = %v, want %v", got, model.InterceptionRedirect)
+		}
+		if node != nil {
+			if got := node.GetRouterMode(); got != model.StandardRouter {
+				t.Errorf("GetRouterMode() = %v, want %v", got, model.StandardRouter)
+			}
+		}
+	}
+
+	node := &model.Proxy{Metadata: &model.NodeMetadata{ClusterID: "cluster1"}, NamespaceLabels: map[string]string{"env": "prod"}}
+	assert.Equal(t, node.GetClusterID(), "cluster1")
+	assert.Equal(t, node.GetNamespaceLabels(), map[string]string{"env": "prod"})
+}
@@ -120,3 +120,132 @@ func (ps *PushContext) inheritDestinationRule(parent, child *config.Config) *con
 
 	return &merged
 }
+
+// DestinationRuleExportedNamespaces returns the namespaces the rules are exported to, or nil if any is exported to all.
+func DestinationRuleExportedNamespaces(configs ...config.Config) map[string]struct{} {
+	namespaces := map[string]struct{}{}
+	found := false
+	for _, cfg := range configs {
+		rule, ok := cfg.Spec.(*networking.DestinationRule)
+		if !ok {
+			continue
+		}
+		found = true
+		// Regardless of the mesh default, to err on the side of pushing.
+		if len(rule.ExportTo) == 0 {
+			return nil
+		}
+		for _, e := range rule.ExportTo {
+			switch visibility.Instance(e) {
+			case visibility.Public:
+				return nil
+			case visibility.Private:
+				namespaces[cfg.Namespace] = struct{}{}
+			default:
+				namespaces[e] = struct{}{}
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return namespaces
+}
+
+// DestinationRuleHosts returns the hosts of the destination rules, resolved to FQDNs in the namespace of each rule.
+func DestinationRuleHosts(configs ...config.Config) []host.Name {
+	var hosts []host.Name
+	for _, cfg := range configs {
+		rule, ok := cfg.Spec.(*networking.DestinationRule)
+		if !ok {
+			continue
+		}
+		hostname := ResolveShortnameToFQDN(rule.Host, cfg.Meta)
+		if !containsHost(hosts, hostname) {
+			hosts = append(hosts, hostname)
+		}
+	}
+	return hosts
+}
+
+func containsHost(hosts []host.Name, hostname host.Name) bool {
+	for _, h := range hosts {
+		if h == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// DestinationRuleChangeKind classifies a DestinationRule change by the parts of the configuration it affects,
+// so that routes are only pushed when they may change.
+type DestinationRuleChangeKind int
+
+const (
+	// DestinationRuleChangeStructural is a change to the host, exportTo or subsets of a destination rule, or
+	// to its consistent hash settings, affecting routes as well as clusters. This is the default when nothing
+	// more specific is known.
+	DestinationRuleChangeStructural DestinationRuleChangeKind = iota
+	// DestinationRuleChangeTrafficPolicy is a change to the traffic policies, other than consistent hashing,
+	// or the labels of otherwise unchanged subsets. It affects clusters and endpoints only.
+	DestinationRuleChangeTrafficPolicy
+)
+
+// ClassifyDestinationRuleChange returns the kind of change between two versions of a DestinationRule.
+func ClassifyDestinationRuleChange(old, curr config.Config) DestinationRuleChangeKind {
+	o, ok := old.Spec.(*networking.DestinationRule)
+	if !ok {
+		return DestinationRuleChangeStructural
+	}
+	n, ok := curr.Spec.(*networking.DestinationRule)
+	if !ok {
+		return DestinationRuleChangeStructural
+	}
+	if !proto.Equal(destinationRuleRouteSettings(o), destinationRuleRouteSettings(n)) {
+		return DestinationRuleChangeStructural
+	}
+	return DestinationRuleChangeTrafficPolicy
+}
+
+// destinationRuleRouteSettings returns the parts of the destination rule routes are built from: the subset
+// names their clusters are referenced by, and consistent hashing their hash policies are taken from.
+func destinationRuleRouteSettings(rule *networking.DestinationRule) *networking.DestinationRule {
+	out := &networking.DestinationRule{
+		Host:          rule.Host,
+		ExportTo:      rule.ExportTo,
+		TrafficPolicy: consistentHashSettings(rule.TrafficPolicy),
+	}
+	for _, subset := range rule.Subsets {
+		out.Subsets = append(out.Subsets, &networking.Subset{
+			Name:          subset.Name,
+			TrafficPolicy: consistentHashSettings(subset.TrafficPolicy),
+		})
+	}
+	return out
+}
+
+// consistentHashSettings returns the consistent hash load balancing settings of the traffic policy, overall
+// and per port.
+func consistentHashSettings(policy *networking.TrafficPolicy) *networking.TrafficPolicy {
+	if policy == nil {
+		return nil
+	}
+	out := &networking.TrafficPolicy{LoadBalancer: consistentHashLoadBalancer(policy.LoadBalancer)}
+	for _, settings := range policy.PortLevelSettings {
+		if lb := consistentHashLoadBalancer(settings.LoadBalancer); lb != nil {
+			out.PortLevelSettings = append(out.PortLevelSettings, &networking.TrafficPolicy_PortTrafficPolicy{
+				Port:         settings.Port,
+				LoadBalancer: lb,
+			})
+		}
+	}
+	return out
+}
+
+func consistentHashLoadBalancer(lb *networking.LoadBalancerSettings) *networking.LoadBalancerSettings {
+	hash := lb.GetConsistentHash()
+	if hash == nil {
+		return nil
+	}
+	return &networking.LoadBalancerSettings{LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{ConsistentHash: hash}}
+}
@@ -146,3 +146,98 @@ func proxyMatch(proxy *Proxy, cp *EnvoyFilterConfigPatchWrapper) bool {
 	}
 	return true
 }
+
+// EnvoyFilterTarget is the type of proxies changes to EnvoyFilters can affect.
+type EnvoyFilterTarget int
+
+const (
+	// EnvoyFilterTargetAll is used when the changes may affect any proxy. This is the default when
+	// nothing more specific is known.
+	EnvoyFilterTargetAll EnvoyFilterTarget = iota
+	// EnvoyFilterTargetSidecars is used when all patches only apply to sidecar listeners, routes and clusters.
+	EnvoyFilterTargetSidecars
+	// EnvoyFilterTargetGateways is used when all patches only apply to gateway listeners, routes and clusters.
+	EnvoyFilterTargetGateways
+)
+
+// EnvoyFilterTargetOf returns the type of proxies the patches of the EnvoyFilters apply to.
+func EnvoyFilterTargetOf(configs ...config.Config) EnvoyFilterTarget {
+	sidecars, gateways, found := false, false, false
+	for _, cfg := range configs {
+		ef, ok := cfg.Spec.(*networking.EnvoyFilter)
+		if !ok {
+			continue
+		}
+		found = true
+		for _, cp := range ef.ConfigPatches {
+			// Extension configurations are not matched by context.
+			if cp.ApplyTo == networking.EnvoyFilter_EXTENSION_CONFIG {
+				return EnvoyFilterTargetAll
+			}
+			switch cp.GetMatch().GetContext() {
+			case networking.EnvoyFilter_SIDECAR_INBOUND, networking.EnvoyFilter_SIDECAR_OUTBOUND:
+				sidecars = true
+			case networking.EnvoyFilter_GATEWAY:
+				gateways = true
+			default:
+				return EnvoyFilterTargetAll
+			}
+		}
+	}
+	switch {
+	case !found || sidecars == gateways:
+		return EnvoyFilterTargetAll
+	case sidecars:
+		return EnvoyFilterTargetSidecars
+	default:
+		return EnvoyFilterTargetGateways
+	}
+}
+
+// statsFilterNames are the names of the filters only producing stats.
+var statsFilterNames = map[string]struct{}{
+	"istio.stats": {},
+}
+
+// EnvoyFilterStatsOnly returns whether all patches of the EnvoyFilters only apply to stats filters.
+func EnvoyFilterStatsOnly(configs ...config.Config) bool {
+	found := false
+	for _, cfg := range configs {
+		ef, ok := cfg.Spec.(*networking.EnvoyFilter)
+		if !ok {
+			continue
+		}
+		found = true
+		for _, cp := range ef.ConfigPatches {
+			if !isStatsFilterPatch(cp) {
+				return false
+			}
+		}
+	}
+	return found
+}
+
+// isStatsFilterPatch returns whether the patch only applies to a stats filter, either matched by name or
+// inserted by it.
+func isStatsFilterPatch(cp *networking.EnvoyFilter_EnvoyConfigObjectPatch) bool {
+	var matched string
+	switch cp.ApplyTo {
+	case networking.EnvoyFilter_HTTP_FILTER:
+		matched = cp.GetMatch().GetListener().GetFilterChain().GetFilter().GetSubFilter().GetName()
+	case networking.EnvoyFilter_NETWORK_FILTER:
+		matched = cp.GetMatch().GetListener().GetFilterChain().GetFilter().GetName()
+	default:
+		return false
+	}
+	_, matchesStats := statsFilterNames[matched]
+	_, valueIsStats := statsFilterNames[cp.GetPatch().GetValue().GetFields()["name"].GetStringValue()]
+	switch cp.GetPatch().GetOperation() {
+	case networking.EnvoyFilter_Patch_MERGE, networking.EnvoyFilter_Patch_REMOVE:
+		return matchesStats
+	case networking.EnvoyFilter_Patch_REPLACE:
+		return matchesStats && valueIsStats
+	default:
+		// Inserted filters are positioned relative to the matched filter, but do not change it.
+		return valueIsStats
+	}
+}
@@ -17,6 +17,8 @@ package model
 import (
 	"testing"
 
+	"github.com/gogo/protobuf/types"
+
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
 )
@@ -114,3 +116,142 @@ func TestEnvoyFilterMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestEnvoyFilterTargetOf(t *testing.T) {
+	filter := func(applyTo networking.EnvoyFilter_ApplyTo, contexts ...networking.EnvoyFilter_PatchContext) config.Config {
+		ef := &networking.EnvoyFilter{}
+		for _, c := range contexts {
+			ef.ConfigPatches = append(ef.ConfigPatches, &networking.EnvoyFilter_EnvoyConfigObjectPatch{
+				ApplyTo: applyTo,
+				Match:   &networking.EnvoyFilter_EnvoyConfigObjectMatch{Context: c},
+			})
+		}
+		return config.Config{Spec: ef}
+	}
+	cases := []struct {
+		name    string
+		configs []config.Config
+		want    EnvoyFilterTarget
+	}{
+		{
+			name:    "gateway",
+			configs: []config.Config{filter(networking.EnvoyFilter_LISTENER, networking.EnvoyFilter_GATEWAY)},
+			want:    EnvoyFilterTargetGateways,
+		},
+		{
+			name: "sidecar",
+			configs: []config.Config{filter(networking.EnvoyFilter_HTTP_FILTER,
+				networking.EnvoyFilter_SIDECAR_INBOUND, networking.EnvoyFilter_SIDECAR_OUTBOUND)},
+			want: EnvoyFilterTargetSidecars,
+		},
+		{
+			name:    "any",
+			configs: []config.Config{filter(networking.EnvoyFilter_CLUSTER, networking.EnvoyFilter_ANY)},
+			want:    EnvoyFilterTargetAll,
+		},
+		{
+			name:    "extension config",
+			configs: []config.Config{filter(networking.EnvoyFilter_EXTENSION_CONFIG, networking.EnvoyFilter_GATEWAY)},
+			want:    EnvoyFilterTargetAll,
+		},
+		{
+			name: "changed from sidecar to gateway",
+			configs: []config.Config{
+				filter(networking.EnvoyFilter_LISTENER, networking.EnvoyFilter_SIDECAR_INBOUND),
+				filter(networking.EnvoyFilter_LISTENER, networking.EnvoyFilter_GATEWAY),
+			},
+			want: EnvoyFilterTargetAll,
+		},
+		{
+			name:    "added",
+			configs: []config.Config{{}, filter(networking.EnvoyFilter_LISTENER, networking.EnvoyFilter_GATEWAY)},
+			want:    EnvoyFilterTargetGateways,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EnvoyFilterTargetOf(tt.configs...); got != tt.want {
+				t.Fatalf("EnvoyFilterTargetOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvoyFilterStatsOnly(t *testing.T) {
+	named := func(name string) *types.Struct {
+		return &types.Struct{Fields: map[string]*types.Value{"name": {Kind: &types.Value_StringValue{StringValue: name}}}}
+	}
+	httpFilter := func(op networking.EnvoyFilter_Patch_Operation, matched string, value *types.Struct) *networking.EnvoyFilter_EnvoyConfigObjectPatch {
+		return &networking.EnvoyFilter_EnvoyConfigObjectPatch{
+			ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+			Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+				ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+					Listener: &networking.EnvoyFilter_ListenerMatch{
+						FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+							Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+								Name:      "envoy.filters.network.http_connection_manager",
+								SubFilter: &networking.EnvoyFilter_ListenerMatch_SubFilterMatch{Name: matched},
+							},
+						},
+					},
+				},
+			},
+			Patch: &networking.EnvoyFilter_Patch{Operation: op, Value: value},
+		}
+	}
+	filter := func(patches ...*networking.EnvoyFilter_EnvoyConfigObjectPatch) config.Config {
+		return config.Config{Spec: &networking.EnvoyFilter{ConfigPatches: patches}}
+	}
+	cases := []struct {
+		name    string
+		configs []config.Config
+		want    bool
+	}{
+		{
+			name:    "insert stats filter",
+			configs: []config.Config{filter(httpFilter(networking.EnvoyFilter_Patch_INSERT_BEFORE, "envoy.router", named("istio.stats")))},
+			want:    true,
+		},
+		{
+			name:    "merge into stats filter",
+			configs: []config.Config{filter(httpFilter(networking.EnvoyFilter_Patch_MERGE, "istio.stats", named("")))},
+			want:    true,
+		},
+		{
+			name:    "merge into router",
+			configs: []config.Config{filter(httpFilter(networking.EnvoyFilter_Patch_MERGE, "envoy.router", named("")))},
+			want:    false,
+		},
+		{
+			name:    "replace stats filter",
+			configs: []config.Config{filter(httpFilter(networking.EnvoyFilter_Patch_REPLACE, "istio.stats", named("envoy.filters.http.rbac")))},
+			want:    false,
+		},
+		{
+			name: "stats and cluster patches",
+			configs: []config.Config{filter(
+				httpFilter(networking.EnvoyFilter_Patch_INSERT_BEFORE, "envoy.router", named("istio.stats")),
+				&networking.EnvoyFilter_EnvoyConfigObjectPatch{ApplyTo: networking.EnvoyFilter_CLUSTER},
+			)},
+			want: false,
+		},
+		{
+			name: "added",
+			configs: []config.Config{{},
+				filter(httpFilter(networking.EnvoyFilter_Patch_INSERT_BEFORE, "envoy.router", named("istio.stats")))},
+			want: true,
+		},
+		{
+			name:    "no envoy filter",
+			configs: []config.Config{{}},
+			want:    false,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EnvoyFilterStatsOnly(tt.configs...); got != tt.want {
+				t.Fatalf("EnvoyFilterStatsOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,10 +16,13 @@ package model
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/gogo/protobuf/proto"
+
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
@@ -85,6 +88,76 @@ func RecordRejectedConfig(gatewayName string) {
 	totalRejectedConfigs.With(typeTag.Value("gateway"), nameTag.Value(gatewayName)).Increment()
 }
 
+// GatewayChangeKind classifies a Gateway change by the parts of the configuration it affects, so that
+// only the affected xDS types are pushed to gateways.
+type GatewayChangeKind int
+
+const (
+	// GatewayChangeStructural is a change to the servers, hosts or ports of a gateway, affecting both
+	// listeners and routes. This is the default when nothing more specific is known.
+	GatewayChangeStructural GatewayChangeKind = iota
+	// GatewayChangeTLS is a change to the TLS settings, such as certificates, of otherwise unchanged servers.
+	// It affects listeners and secrets only.
+	GatewayChangeTLS
+	// GatewayChangeRouting is a change affecting only the selection of routes, and only requires RDS.
+	GatewayChangeRouting
+	// GatewayChangeMetadata is a change to the metadata, such as labels or annotations, of a gateway whose
+	// servers and selector are unchanged. Gateways are built from their spec only, so it affects no xDS type.
+	GatewayChangeMetadata
+)
+
+// ClassifyGatewayChange returns the kind of change between two versions of a Gateway.
+func ClassifyGatewayChange(old, curr config.Config) GatewayChangeKind {
+	o, ok := old.Spec.(*networking.Gateway)
+	if !ok {
+		return GatewayChangeStructural
+	}
+	n, ok := curr.Spec.(*networking.Gateway)
+	if !ok {
+		return GatewayChangeStructural
+	}
+	if len(o.Servers) != len(n.Servers) || !reflect.DeepEqual(o.Selector, n.Selector) {
+		return GatewayChangeStructural
+	}
+	tlsChanged, hostsChanged := false, false
+	for i := range o.Servers {
+		oldServer, newServer := o.Servers[i], n.Servers[i]
+		// TLS modes shape the filter chains and passthrough clusters, HTTPS redirects are part of the routes.
+		if (oldServer.Tls == nil) != (newServer.Tls == nil) ||
+			oldServer.GetTls().GetMode() != newServer.GetTls().GetMode() ||
+			oldServer.GetTls().GetHttpsRedirect() != newServer.GetTls().GetHttpsRedirect() {
+			return GatewayChangeStructural
+		}
+		if !proto.Equal(oldServer.Tls, newServer.Tls) {
+			tlsChanged = true
+		}
+		if !reflect.DeepEqual(oldServer.Hosts, newServer.Hosts) {
+			// Only the hosts of plain HTTP servers are limited to selecting virtual hosts, others are SNI matches.
+			if newServer.Tls != nil || !protocol.Parse(newServer.GetPort().GetProtocol()).IsHTTP() {
+				return GatewayChangeStructural
+			}
+			hostsChanged = true
+		}
+		oldRest := proto.Clone(oldServer).(*networking.Server)
+		newRest := proto.Clone(newServer).(*networking.Server)
+		oldRest.Tls, newRest.Tls = nil, nil
+		oldRest.Hosts, newRest.Hosts = nil, nil
+		if !proto.Equal(oldRest, newRest) {
+			return GatewayChangeStructural
+		}
+	}
+	switch {
+	case tlsChanged && hostsChanged:
+		return GatewayChangeStructural
+	case tlsChanged:
+		return GatewayChangeTLS
+	case hostsChanged:
+		return GatewayChangeRouting
+	default:
+		return GatewayChangeMetadata
+	}
+}
+
 // MergeGateways combines multiple gateways targeting the same workload into a single logical Gateway.
 // Note that today any Servers in the combined gateways listening on the same port must have the same protocol.
 // If servers with different protocols attempt to listen on the same port, one of the protocols will be chosen at random.
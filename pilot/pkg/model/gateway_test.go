@@ -202,3 +202,137 @@ func TestParseGatewayRDSRouteName(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifyGatewayChange(t *testing.T) {
+	base := makeConfig("gw", "ns", "foo.bar.com", "https", "https", 443, "ingressgateway")
+	base.Spec.(*networking.Gateway).Servers[0].Tls = &networking.ServerTLSSettings{
+		Mode:           networking.ServerTLSSettings_SIMPLE,
+		CredentialName: "cert",
+	}
+	modify := func(f func(s *networking.Server)) config.Config {
+		c := base.DeepCopy()
+		f(c.Spec.(*networking.Gateway).Servers[0])
+		return c
+	}
+
+	tests := []struct {
+		name string
+		curr config.Config
+		want GatewayChangeKind
+	}{
+		{
+			name: "credential change",
+			curr: modify(func(s *networking.Server) { s.Tls.CredentialName = "other-cert" }),
+			want: GatewayChangeTLS,
+		},
+		{
+			name: "tls mode change",
+			curr: modify(func(s *networking.Server) { s.Tls.Mode = networking.ServerTLSSettings_PASSTHROUGH }),
+			want: GatewayChangeStructural,
+		},
+		{
+			name: "https redirect change",
+			curr: modify(func(s *networking.Server) { s.Tls.HttpsRedirect = true }),
+			want: GatewayChangeStructural,
+		},
+		{
+			name: "hosts change",
+			curr: modify(func(s *networking.Server) { s.Hosts = []string{"other.bar.com"} }),
+			want: GatewayChangeStructural,
+		},
+		{
+			name: "port and credential change",
+			curr: modify(func(s *networking.Server) {
+				s.Port.Number = 8443
+				s.Tls.CredentialName = "other-cert"
+			}),
+			want: GatewayChangeStructural,
+		},
+		{
+			name: "no change",
+			curr: base.DeepCopy(),
+			want: GatewayChangeMetadata,
+		},
+		{
+			name: "labels change",
+			curr: func() config.Config {
+				c := base.DeepCopy()
+				c.Labels = map[string]string{"team": "other"}
+				c.Annotations = map[string]string{"note": "relabeled"}
+				return c
+			}(),
+			want: GatewayChangeMetadata,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyGatewayChange(base, tt.curr); got != tt.want {
+				t.Errorf("ClassifyGatewayChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyGatewayChangeHTTPHosts(t *testing.T) {
+	base := makeConfig("gw", "ns", "foo.bar.com", "http", "HTTP", 80, "ingressgateway")
+	modify := func(f func(s *networking.Server)) config.Config {
+		c := base.DeepCopy()
+		f(c.Spec.(*networking.Gateway).Servers[0])
+		return c
+	}
+
+	tests := []struct {
+		name string
+		curr config.Config
+		want GatewayChangeKind
+	}{
+		{
+			name: "hosts change",
+			curr: modify(func(s *networking.Server) { s.Hosts = []string{"foo.bar.com", "other.bar.com"} }),
+			want: GatewayChangeRouting,
+		},
+		{
+			name: "hosts namespace change",
+			curr: modify(func(s *networking.Server) { s.Hosts = []string{"other/foo.bar.com"} }),
+			want: GatewayChangeRouting,
+		},
+		{
+			name: "hosts and port change",
+			curr: modify(func(s *networking.Server) {
+				s.Hosts = []string{"other.bar.com"}
+				s.Port.Number = 8080
+			}),
+			want: GatewayChangeStructural,
+		},
+		{
+			name: "hosts and bind change",
+			curr: modify(func(s *networking.Server) {
+				s.Hosts = []string{"other.bar.com"}
+				s.Bind = "10.0.0.1"
+			}),
+			want: GatewayChangeStructural,
+		},
+		{
+			name: "hosts change adding tls",
+			curr: modify(func(s *networking.Server) {
+				s.Hosts = []string{"other.bar.com"}
+				s.Tls = &networking.ServerTLSSettings{HttpsRedirect: true}
+			}),
+			want: GatewayChangeStructural,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyGatewayChange(base, tt.curr); got != tt.want {
+				t.Errorf("ClassifyGatewayChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	tcp := makeConfig("gw", "ns", "foo.bar.com", "tcp", "TCP", 31400, "ingressgateway")
+	tcpHosts := tcp.DeepCopy()
+	tcpHosts.Spec.(*networking.Gateway).Servers[0].Hosts = []string{"other.bar.com"}
+	if got := ClassifyGatewayChange(tcp, tcpHosts); got != GatewayChangeStructural {
+		t.Errorf("ClassifyGatewayChange() for TCP hosts change = %v, want %v", got, GatewayChangeStructural)
+	}
+}
@@ -15,7 +15,10 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net"
 	"sort"
 	"strings"
@@ -184,6 +187,10 @@ type PushContext struct {
 	// AuthnPolicies contains Authn policies by namespace.
 	AuthnPolicies *AuthenticationPolicies `json:"-"`
 
+	// prevAuthnPolicies contains the Authn policies of the push context this one was updated from, so
+	// that changes to a policy can be scoped by both its previous and current version.
+	prevAuthnPolicies *AuthenticationPolicies
+
 	// AuthzPolicies stores the existing authorization policies in the cluster. Could be nil if there
 	// are no authorization policies in the cluster.
 	AuthzPolicies *AuthorizationPolicies `json:"-"`
@@ -307,6 +314,88 @@ type PushRequest struct {
 	// There should only be multiple reasons if the push request is the result of two distinct triggers, rather than
 	// classifying a single trigger as having multiple reasons.
 	Reason []TriggerReason
+
+	// CorrelationID identifies the change that triggered the push, so a single config change can be traced
+	// from ingestion through debouncing to the per-proxy push decisions. When requests are merged, the ID
+	// of the first (older) request is kept.
+	CorrelationID string
+
+	// GatewayChange classifies the Gateway changes in ConfigsUpdated, allowing gateways to be pushed only
+	// the affected xDS types. It is ignored if no Gateway changed.
+	GatewayChange GatewayChangeKind
+
+	// EnvoyFilterTarget is the type of proxies the EnvoyFilter changes in ConfigsUpdated apply to, allowing
+	// other proxies to be skipped. It is ignored if no EnvoyFilter changed.
+	EnvoyFilterTarget EnvoyFilterTarget
+
+	// DestinationRuleNamespaces are the namespaces the DestinationRule changes in ConfigsUpdated are exported
+	// to, allowing proxies in other namespaces to be skipped. If nil, the changes may affect any namespace. It is
+	// ignored if no DestinationRule changed.
+	DestinationRuleNamespaces map[string]struct{}
+
+	// DestinationRuleChange classifies the DestinationRule changes in ConfigsUpdated, allowing routes to be
+	// skipped when only clusters change. It is ignored if no DestinationRule changed.
+	DestinationRuleChange DestinationRuleChangeKind
+
+	// VirtualServiceChange classifies the VirtualService changes in ConfigsUpdated, allowing listeners to be
+	// skipped when only routes change. It is ignored if no VirtualService changed.
+	VirtualServiceChange VirtualServiceChangeKind
+
+	// DestinationRuleHosts are the hosts of the DestinationRule changes in ConfigsUpdated, before and after
+	// the change, where known. Sidecars importing one of the hosts are pushed even if the rule is not among
+	// the dependencies of their scope, such as when a rule for the host was renamed.
+	DestinationRuleHosts map[ConfigKey][]host.Name
+
+	// ConfigGenerations are the generations of the configs in ConfigsUpdated, where known. Updates for
+	// generations older than one already seen are stale, and are dropped when the request is received.
+	ConfigGenerations map[ConfigKey]int64
+
+	// ClusterIDs are the IDs of the clusters whose proxies the changes can affect, for changes to resources
+	// that are not visible across clusters, such as the endpoints of cluster-local services. If nil, proxies in
+	// all clusters may be affected.
+	ClusterIDs map[string]struct{}
+
+	// ServicePorts are the ports of the services changed by the ServiceEntry updates in ConfigsUpdated, where
+	// known, allowing sidecars that do not import any of them to be skipped. Updates without ports may affect
+	// any port of the service.
+	ServicePorts map[ConfigKey][]int
+
+	// EndpointDeltas are the changes to the endpoints of the services updated in ConfigsUpdated, where known,
+	// allowing EDS to be skipped for services whose endpoints did not change. Services without a delta must
+	// have all their endpoints recomputed.
+	EndpointDeltas map[ConfigKey]*EndpointDelta
+
+	// DrainingServices are the ServiceEntry updates in ConfigsUpdated for services that are being drained
+	// and removed, allowing EDS for their hosts to be skipped. Their clusters are about to be removed, so
+	// recomputing their endpoints is wasted. The discovery server sets them for services whose last endpoint
+	// shard was removed by a service deletion.
+	DrainingServices map[ConfigKey]struct{}
+
+	// NamespaceSelectors select, by their labels, the namespaces whose proxies the changes can affect. Proxies
+	// in namespaces matching none of them are skipped. If empty, proxies in all namespaces may be affected.
+	// Namespace labels are taken from the service registries (see NamespaceLabelsGetter); no registry event
+	// sets selectors yet.
+	NamespaceSelectors labels.Collection
+
+	// StatsOnly is set when the changes in ConfigsUpdated only change stats filters, which are part of
+	// listeners only, allowing all other xDS types to be skipped.
+	StatsOnly bool
+}
+
+// EndpointDelta is a change to the endpoints of a service in a single cluster.
+type EndpointDelta struct {
+	// ClusterID is the cluster the endpoints are in.
+	ClusterID string
+	// Added are the endpoints that were added or changed.
+	Added []*IstioEndpoint
+	// Removed are the endpoints that were removed, or replaced by changed endpoints in Added.
+	Removed []*IstioEndpoint
+}
+
+// SetCorrelationID sets the correlation ID of the push request and returns the request.
+func (pr *PushRequest) SetCorrelationID(id string) *PushRequest {
+	pr.CorrelationID = id
+	return pr
 }
 
 type TriggerReason string
@@ -356,6 +445,161 @@ func (first *PushRequest) Merge(other *PushRequest) *PushRequest {
 
 		// Merge the two reasons. Note that we shouldn't deduplicate here, or we would under count
 		Reason: reason,
+
+		// Keep the first (older) correlation ID, if any
+		CorrelationID: first.CorrelationID,
+
+		// Only stats filters change if that holds for the changes of both requests
+		StatsOnly: first.StatsOnly && other.StatsOnly,
+	}
+	if merged.CorrelationID == "" {
+		merged.CorrelationID = other.CorrelationID
+	}
+
+	// Gateway change kinds can only be kept if all Gateway changes are of the same kind
+	switch firstGw, otherGw := first.updatesKind(gvk.Gateway), other.updatesKind(gvk.Gateway); {
+	case firstGw && otherGw:
+		if first.GatewayChange == other.GatewayChange {
+			merged.GatewayChange = first.GatewayChange
+		}
+	case firstGw:
+		merged.GatewayChange = first.GatewayChange
+	case otherGw:
+		merged.GatewayChange = other.GatewayChange
+	}
+
+	// Likewise, EnvoyFilter targets can only be kept if all EnvoyFilter changes have the same target
+	switch firstEf, otherEf := first.updatesKind(gvk.EnvoyFilter), other.updatesKind(gvk.EnvoyFilter); {
+	case firstEf && otherEf:
+		if first.EnvoyFilterTarget == other.EnvoyFilterTarget {
+			merged.EnvoyFilterTarget = first.EnvoyFilterTarget
+		}
+	case firstEf:
+		merged.EnvoyFilterTarget = first.EnvoyFilterTarget
+	case otherEf:
+		merged.EnvoyFilterTarget = other.EnvoyFilterTarget
+	}
+
+	// DestinationRule change kinds can only be kept if all DestinationRule changes are of the same kind
+	switch firstDr, otherDr := first.updatesKind(gvk.DestinationRule), other.updatesKind(gvk.DestinationRule); {
+	case firstDr && otherDr:
+		if first.DestinationRuleChange == other.DestinationRuleChange {
+			merged.DestinationRuleChange = first.DestinationRuleChange
+		}
+	case firstDr:
+		merged.DestinationRuleChange = first.DestinationRuleChange
+	case otherDr:
+		merged.DestinationRuleChange = other.DestinationRuleChange
+	}
+
+	// VirtualService change kinds can only be kept if all VirtualService changes are of the same kind
+	switch firstVs, otherVs := first.updatesKind(gvk.VirtualService), other.updatesKind(gvk.VirtualService); {
+	case firstVs && otherVs:
+		if first.VirtualServiceChange == other.VirtualServiceChange {
+			merged.VirtualServiceChange = first.VirtualServiceChange
+		}
+	case firstVs:
+		merged.VirtualServiceChange = first.VirtualServiceChange
+	case otherVs:
+		merged.VirtualServiceChange = other.VirtualServiceChange
+	}
+
+	// DestinationRule namespaces are combined, unless either request may affect any namespace
+	switch firstDr, otherDr := first.updatesKind(gvk.DestinationRule), other.updatesKind(gvk.DestinationRule); {
+	case firstDr && otherDr:
+		merged.DestinationRuleNamespaces = unionOrAll(first.DestinationRuleNamespaces, other.DestinationRuleNamespaces)
+	case firstDr:
+		merged.DestinationRuleNamespaces = first.DestinationRuleNamespaces
+	case otherDr:
+		merged.DestinationRuleNamespaces = other.DestinationRuleNamespaces
+	}
+
+	// DestinationRule hosts are combined, as each only adds sidecars to push
+	if len(first.DestinationRuleHosts) > 0 || len(other.DestinationRuleHosts) > 0 {
+		merged.DestinationRuleHosts = map[ConfigKey][]host.Name{}
+		for _, hosts := range []map[ConfigKey][]host.Name{first.DestinationRuleHosts, other.DestinationRuleHosts} {
+			for conf, hs := range hosts {
+				for _, h := range hs {
+					if !containsHost(merged.DestinationRuleHosts[conf], h) {
+						merged.DestinationRuleHosts[conf] = append(merged.DestinationRuleHosts[conf], h)
+					}
+				}
+			}
+		}
+	}
+
+	// Keep the newest generation of each config
+	if len(first.ConfigGenerations) > 0 || len(other.ConfigGenerations) > 0 {
+		merged.ConfigGenerations = make(map[ConfigKey]int64, len(first.ConfigGenerations)+len(other.ConfigGenerations))
+		for _, generations := range []map[ConfigKey]int64{first.ConfigGenerations, other.ConfigGenerations} {
+			for conf, generation := range generations {
+				if generation > merged.ConfigGenerations[conf] {
+					merged.ConfigGenerations[conf] = generation
+				}
+			}
+		}
+	}
+
+	// Cluster IDs are combined, unless either request may affect all clusters
+	merged.ClusterIDs = unionOrAll(first.ClusterIDs, other.ClusterIDs)
+
+	// Namespace selectors are combined, unless either request may affect any namespace
+	if len(first.NamespaceSelectors) > 0 && len(other.NamespaceSelectors) > 0 {
+		merged.NamespaceSelectors = make(labels.Collection, 0, len(first.NamespaceSelectors)+len(other.NamespaceSelectors))
+		merged.NamespaceSelectors = append(merged.NamespaceSelectors, first.NamespaceSelectors...)
+		merged.NamespaceSelectors = append(merged.NamespaceSelectors, other.NamespaceSelectors...)
+	}
+
+	// Ports are combined for services updated by both requests, unless either may affect any port
+	if len(first.ServicePorts) > 0 || len(other.ServicePorts) > 0 {
+		merged.ServicePorts = map[ConfigKey][]int{}
+		for conf, ports := range first.ServicePorts {
+			if _, f := other.ConfigsUpdated[conf]; !f {
+				merged.ServicePorts[conf] = ports
+			} else if otherPorts, f := other.ServicePorts[conf]; f {
+				merged.ServicePorts[conf] = mergePorts(ports, otherPorts)
+			}
+		}
+		for conf, ports := range other.ServicePorts {
+			if _, f := first.ConfigsUpdated[conf]; !f {
+				merged.ServicePorts[conf] = ports
+			}
+		}
+	}
+
+	// Deltas only apply on their own, drop those of services updated by both requests. If either request may
+	// update anything, no delta can be relied on.
+	if len(first.ConfigsUpdated) > 0 && len(other.ConfigsUpdated) > 0 &&
+		(len(first.EndpointDeltas) > 0 || len(other.EndpointDeltas) > 0) {
+		merged.EndpointDeltas = map[ConfigKey]*EndpointDelta{}
+		for conf, delta := range first.EndpointDeltas {
+			if _, f := other.ConfigsUpdated[conf]; !f {
+				merged.EndpointDeltas[conf] = delta
+			}
+		}
+		for conf, delta := range other.EndpointDeltas {
+			if _, f := first.ConfigsUpdated[conf]; !f {
+				merged.EndpointDeltas[conf] = delta
+			}
+		}
+	}
+
+	// Services are only draining if every request updating them drains them. If either request may update
+	// anything, no service can be considered draining.
+	if len(first.ConfigsUpdated) > 0 && len(other.ConfigsUpdated) > 0 &&
+		(len(first.DrainingServices) > 0 || len(other.DrainingServices) > 0) {
+		merged.DrainingServices = map[ConfigKey]struct{}{}
+		for conf := range first.DrainingServices {
+			_, updated := other.ConfigsUpdated[conf]
+			if _, draining := other.DrainingServices[conf]; !updated || draining {
+				merged.DrainingServices[conf] = struct{}{}
+			}
+		}
+		for conf := range other.DrainingServices {
+			if _, updated := first.ConfigsUpdated[conf]; !updated {
+				merged.DrainingServices[conf] = struct{}{}
+			}
+		}
 	}
 
 	// Do not merge when any one is empty
@@ -372,6 +616,131 @@ func (first *PushRequest) Merge(other *PushRequest) *PushRequest {
 	return merged
 }
 
+// unionOrAll returns the union of two sets where nil stands for all values, so it is nil if either one is.
+func unionOrAll(first, other map[string]struct{}) map[string]struct{} {
+	if first == nil || other == nil {
+		return nil
+	}
+	out := make(map[string]struct{}, len(first)+len(other))
+	for v := range first {
+		out[v] = struct{}{}
+	}
+	for v := range other {
+		out[v] = struct{}{}
+	}
+	return out
+}
+
+// Fingerprint returns a stable hash of what the push request asks to be pushed: whether it is full, the
+// hints classifying its Gateway, EnvoyFilter, VirtualService, DestinationRule and ServiceEntry changes,
+// whether only stats filters change, the clusters and namespaces it is limited to, and the updated configs.
+// Requests asking for the same push have the same fingerprint, regardless of when they were created, their
+// reasons or their push context.
+func (pr *PushRequest) Fingerprint() string {
+	configs := make([]string, 0, len(pr.ConfigsUpdated))
+	for conf := range pr.ConfigsUpdated {
+		configs = append(configs, conf.Kind.String()+"/"+conf.Namespace+"/"+conf.Name)
+	}
+	sort.Strings(configs)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "full=%t;gateway=%d;envoyfilter=%d;", pr.Full, pr.GatewayChange, pr.EnvoyFilterTarget)
+	if pr.DestinationRuleNamespaces != nil {
+		fmt.Fprintf(h, "destinationrule=%s;", sortedKeys(pr.DestinationRuleNamespaces))
+	}
+	if pr.DestinationRuleChange != DestinationRuleChangeStructural {
+		fmt.Fprintf(h, "destinationrulechange=%d;", pr.DestinationRuleChange)
+	}
+	if pr.VirtualServiceChange != VirtualServiceChangeStructural {
+		fmt.Fprintf(h, "virtualservicechange=%d;", pr.VirtualServiceChange)
+	}
+	if len(pr.DestinationRuleHosts) > 0 {
+		hosts := make([]string, 0, len(pr.DestinationRuleHosts))
+		for conf, hs := range pr.DestinationRuleHosts {
+			names := make([]string, 0, len(hs))
+			for _, hostname := range hs {
+				names = append(names, string(hostname))
+			}
+			sort.Strings(names)
+			hosts = append(hosts, fmt.Sprintf("%s/%s/%s:%v", conf.Kind, conf.Namespace, conf.Name, names))
+		}
+		sort.Strings(hosts)
+		fmt.Fprintf(h, "destinationrulehosts=%s;", strings.Join(hosts, ","))
+	}
+	if len(pr.DrainingServices) > 0 {
+		draining := make([]string, 0, len(pr.DrainingServices))
+		for conf := range pr.DrainingServices {
+			draining = append(draining, conf.Kind.String()+"/"+conf.Namespace+"/"+conf.Name)
+		}
+		sort.Strings(draining)
+		fmt.Fprintf(h, "draining=%s;", strings.Join(draining, ","))
+	}
+	if pr.StatsOnly {
+		h.Write([]byte("statsonly;"))
+	}
+	if pr.ClusterIDs != nil {
+		fmt.Fprintf(h, "clusters=%s;", sortedKeys(pr.ClusterIDs))
+	}
+	if len(pr.NamespaceSelectors) > 0 {
+		selectors := make([]string, 0, len(pr.NamespaceSelectors))
+		for _, selector := range pr.NamespaceSelectors {
+			selectors = append(selectors, "{"+selector.String()+"}")
+		}
+		sort.Strings(selectors)
+		fmt.Fprintf(h, "namespaces=%s;", strings.Join(selectors, ","))
+	}
+	if len(pr.ServicePorts) > 0 {
+		ports := make([]string, 0, len(pr.ServicePorts))
+		for conf, p := range pr.ServicePorts {
+			ports = append(ports, fmt.Sprintf("%s/%s/%s:%v", conf.Kind, conf.Namespace, conf.Name, mergePorts(p, nil)))
+		}
+		sort.Strings(ports)
+		fmt.Fprintf(h, "ports=%s;", strings.Join(ports, ","))
+	}
+	for _, conf := range configs {
+		h.Write([]byte(conf))
+		h.Write([]byte{';'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// mergePorts returns the sorted union of the ports.
+func mergePorts(a, b []int) []int {
+	set := make(map[int]struct{}, len(a)+len(b))
+	for _, p := range a {
+		set[p] = struct{}{}
+	}
+	for _, p := range b {
+		set[p] = struct{}{}
+	}
+	out := make([]int, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// sortedKeys returns the sorted keys of the set, joined by commas.
+func sortedKeys(set map[string]struct{}) string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// updatesKind returns true if a config of the kind is among the configs updated by the request.
+func (pr *PushRequest) updatesKind(kind config.GroupVersionKind) bool {
+	for conf := range pr.ConfigsUpdated {
+		if conf.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
 // ProxyPushStatus represents an event captured during config push to proxies.
 // It may contain additional message and the affected proxy.
 type ProxyPushStatus struct {
@@ -725,6 +1094,43 @@ func (ps *PushContext) DelegateVirtualServicesConfigKey(vses []config.Config) []
 	return out
 }
 
+// GatewayVirtualServicesConfigKey lists the configkeys of the virtual services, including their delegates,
+// that are bound to the gateways merged for the provided proxy and are visible from the proxy's namespace.
+// As when building routes, a virtual service is only bound to a server if one of its hosts matches the hosts
+// the server allows from the namespace of the virtual service.
+func (ps *PushContext) GatewayVirtualServicesConfigKey(proxy *Proxy) []ConfigKey {
+	if proxy.MergedGateway == nil {
+		return nil
+	}
+	gatewayVirtualServices := make(map[string][]config.Config)
+	bound := make(map[ConfigKey]config.Config)
+	for server, gateway := range proxy.MergedGateway.GatewayNameForServer {
+		vses, f := gatewayVirtualServices[gateway]
+		if !f {
+			vses = ps.VirtualServicesForGateway(proxy, gateway)
+			gatewayVirtualServices[gateway] = vses
+		}
+		for _, vs := range vses {
+			key := ConfigKey{Kind: gvk.VirtualService, Namespace: vs.Namespace, Name: vs.Name}
+			if _, f := bound[key]; f {
+				continue
+			}
+			serverHosts := host.NamesForNamespace(server.Hosts, vs.Namespace)
+			if len(serverHosts.Intersection(host.NewNames(vs.Spec.(*networking.VirtualService).Hosts))) > 0 {
+				bound[key] = vs
+			}
+		}
+	}
+
+	out := make([]ConfigKey, 0, len(bound))
+	vses := make([]config.Config, 0, len(bound))
+	for key, vs := range bound {
+		out = append(out, key)
+		vses = append(vses, vs)
+	}
+	return append(out, ps.DelegateVirtualServicesConfigKey(vses)...)
+}
+
 // getSidecarScope returns a SidecarScope object associated with the
 // proxy. The SidecarScope object is a semi-processed view of the service
 // registry, and config state associated with the sidecar crd. The scope contains
@@ -891,7 +1297,13 @@ func (ps *PushContext) getExportedDestinationRuleFromNamespace(owningNamespace s
 // IsClusterLocal indicates whether the endpoints for the service should only be accessible to clients
 // within the cluster.
 func (ps *PushContext) IsClusterLocal(service *Service) bool {
-	_, ok := MostSpecificHostMatch(service.Hostname, nil, ps.clusterLocalHosts)
+	return ps.IsClusterLocalHost(service.Hostname)
+}
+
+// IsClusterLocalHost indicates whether the endpoints for the hostname should only be accessible to clients
+// within the cluster.
+func (ps *PushContext) IsClusterLocalHost(hostname host.Name) bool {
+	_, ok := MostSpecificHostMatch(hostname, nil, ps.clusterLocalHosts)
 	return ok
 }
 
@@ -1065,6 +1477,7 @@ func (ps *PushContext) updateContext(
 	} else {
 		ps.AuthnPolicies = oldPushContext.AuthnPolicies
 	}
+	ps.prevAuthnPolicies = oldPushContext.AuthnPolicies
 
 	if authzChanged {
 		if err := ps.initAuthorizationPolicies(env); err != nil {
@@ -1203,6 +1616,48 @@ func (ps *PushContext) initAuthnPolicies(env *Environment) error {
 	return err
 }
 
+// AuthenticationPolicyAffectsWorkload returns whether the previous or current version of the peer or request
+// authentication policy may apply to a workload in the namespace with the labels. Policies without a selector apply
+// to the whole namespace, or mesh, and peer authentications also change the mTLS settings clients use, so they are
+// considered to affect all workloads. Policies with a selector, which are the only peer authentications that can
+// have port-level settings, only affect the inbound configuration of the workloads they select. This includes the
+// per-port passthrough filter chains, which are built for every port-level setting whether or not the workload
+// serves on that port, so they can not be narrowed down further by the ports. Policies of other kinds are not
+// tracked, and are considered to affect all workloads.
+func (ps *PushContext) AuthenticationPolicyAffectsWorkload(key ConfigKey, namespace string, workloadLabels labels.Collection) bool {
+	if ps.AuthnPolicies == nil || ps.prevAuthnPolicies == nil {
+		return true
+	}
+	found := false
+	for _, policies := range []*AuthenticationPolicies{ps.prevAuthnPolicies, ps.AuthnPolicies} {
+		cfg := policies.getPolicy(key.Kind, key.Namespace, key.Name)
+		if cfg == nil {
+			continue
+		}
+		found = true
+		selector := policySelector(cfg)
+		if len(selector) == 0 {
+			return true
+		}
+		if key.Namespace != namespace && key.Namespace != policies.rootNamespace {
+			continue
+		}
+		if workloadLabels.IsSupersetOf(selector) {
+			return true
+		}
+	}
+	// If the policy is unknown to both versions, we can not tell what it applied to.
+	return !found
+}
+
+// AuthenticationPolicyAffectsClients returns whether the previous or current version of the peer or request
+// authentication policy may change the configuration of clients, which only policies without a selector may do.
+// These are the only policies affecting workloads without inbound listeners.
+func (ps *PushContext) AuthenticationPolicyAffectsClients(key ConfigKey) bool {
+	// Without a namespace or labels, only the policies without a selector are matched.
+	return ps.AuthenticationPolicyAffectsWorkload(key, "", nil)
+}
+
 // Caches list of virtual services
 func (ps *PushContext) initVirtualServices(env *Environment) error {
 	ps.virtualServiceIndex.exportedToNamespaceByGateway = map[string]map[string][]config.Config{}
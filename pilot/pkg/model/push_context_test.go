@@ -108,6 +108,290 @@ func TestMergeUpdateRequest(t *testing.T) {
 				Reason: []TriggerReason{ServiceUpdate, ServiceUpdate, EndpointUpdate},
 			},
 		},
+		{
+			"keep first correlation id",
+			&PushRequest{Full: true, CorrelationID: "first"},
+			&PushRequest{Full: true, CorrelationID: "second"},
+			PushRequest{Full: true, Reason: []TriggerReason{}, CorrelationID: "first"},
+		},
+		{
+			"correlation id from right",
+			&PushRequest{Full: true},
+			(&PushRequest{Full: true}).SetCorrelationID("second"),
+			PushRequest{Full: true, Reason: []TriggerReason{}, CorrelationID: "second"},
+		},
+		{
+			"keep gateway change kind of the only gateway change",
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.Gateway, Name: "gw", Namespace: "ns1"}: {},
+			}, GatewayChange: GatewayChangeTLS},
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: {},
+			}},
+			PushRequest{Full: true, Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.Gateway, Name: "gw", Namespace: "ns1"}:        {},
+				{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: {},
+			}, GatewayChange: GatewayChangeTLS},
+		},
+		{
+			"different gateway change kinds are structural",
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.Gateway, Name: "gw1", Namespace: "ns1"}: {},
+			}, GatewayChange: GatewayChangeTLS},
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.Gateway, Name: "gw2", Namespace: "ns1"}: {},
+			}, GatewayChange: GatewayChangeRouting},
+			PushRequest{Full: true, Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.Gateway, Name: "gw1", Namespace: "ns1"}: {},
+				{Kind: gvk.Gateway, Name: "gw2", Namespace: "ns1"}: {},
+			}, GatewayChange: GatewayChangeStructural},
+		},
+		{
+			"combine destination rule namespaces",
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {},
+			}, DestinationRuleNamespaces: map[string]struct{}{"ns1": {}}},
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns2"}: {},
+			}, DestinationRuleNamespaces: map[string]struct{}{"ns2": {}, "ns3": {}}},
+			PushRequest{Full: true, Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {},
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns2"}: {},
+			}, DestinationRuleNamespaces: map[string]struct{}{"ns1": {}, "ns2": {}, "ns3": {}}},
+		},
+		{
+			"destination rule exported to all namespaces",
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {},
+			}, DestinationRuleNamespaces: map[string]struct{}{"ns1": {}}},
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns2"}: {},
+			}},
+			PushRequest{Full: true, Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {},
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns2"}: {},
+			}},
+		},
+		{
+			"keep newest config generations",
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: {},
+			}, ConfigGenerations: map[ConfigKey]int64{{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: 3}},
+			&PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: {},
+			}, ConfigGenerations: map[ConfigKey]int64{{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: 2}},
+			PushRequest{Full: true, Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: {},
+			}, ConfigGenerations: map[ConfigKey]int64{{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: 3}},
+		},
+		{
+			"combine cluster IDs",
+			&PushRequest{Full: true, ClusterIDs: map[string]struct{}{"cluster-a": {}}},
+			&PushRequest{Full: true, ClusterIDs: map[string]struct{}{"cluster-b": {}}},
+			PushRequest{Full: true, Reason: []TriggerReason{}, ClusterIDs: map[string]struct{}{"cluster-a": {}, "cluster-b": {}}},
+		},
+		{
+			"cluster IDs of all clusters",
+			&PushRequest{Full: true, ClusterIDs: map[string]struct{}{"cluster-a": {}}},
+			&PushRequest{Full: true},
+			PushRequest{Full: true, Reason: []TriggerReason{}},
+		},
+		{
+			"drop endpoint deltas of services updated by both",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}, EndpointDeltas: map[ConfigKey]*EndpointDelta{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {ClusterID: "cluster1"},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {ClusterID: "cluster1"},
+			}},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+			}, EndpointDeltas: map[ConfigKey]*EndpointDelta{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {ClusterID: "cluster2"},
+			}},
+			PushRequest{Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}, EndpointDeltas: map[ConfigKey]*EndpointDelta{
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {ClusterID: "cluster1"},
+			}},
+		},
+		{
+			"drop endpoint deltas when merged with any update",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+			}, EndpointDeltas: map[ConfigKey]*EndpointDelta{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {ClusterID: "cluster1"},
+			}},
+			&PushRequest{Full: true},
+			PushRequest{Full: true, Reason: []TriggerReason{}},
+		},
+		{
+			"keep stats only if both are",
+			&PushRequest{StatsOnly: true},
+			&PushRequest{StatsOnly: true},
+			PushRequest{StatsOnly: true, Reason: []TriggerReason{}},
+		},
+		{
+			"drop stats only if either is not",
+			&PushRequest{StatsOnly: true},
+			&PushRequest{},
+			PushRequest{Reason: []TriggerReason{}},
+		},
+		{
+			"combine namespace selectors",
+			&PushRequest{NamespaceSelectors: labels.Collection{{"team": "a"}}},
+			&PushRequest{NamespaceSelectors: labels.Collection{{"team": "b"}}},
+			PushRequest{Reason: []TriggerReason{}, NamespaceSelectors: labels.Collection{{"team": "a"}, {"team": "b"}}},
+		},
+		{
+			"drop namespace selectors if either selects all",
+			&PushRequest{NamespaceSelectors: labels.Collection{{"team": "a"}}},
+			&PushRequest{},
+			PushRequest{Reason: []TriggerReason{}},
+		},
+		{
+			"keep destination rule change kind if all agree",
+			&PushRequest{
+				ConfigsUpdated:        map[ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {}},
+				DestinationRuleChange: DestinationRuleChangeTrafficPolicy,
+			},
+			&PushRequest{
+				ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {}},
+			},
+			PushRequest{
+				Reason: []TriggerReason{},
+				ConfigsUpdated: map[ConfigKey]struct{}{
+					{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {},
+					{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}:   {},
+				},
+				DestinationRuleChange: DestinationRuleChangeTrafficPolicy,
+			},
+		},
+		{
+			"drop destination rule change kind on disagreement",
+			&PushRequest{
+				ConfigsUpdated:        map[ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {}},
+				DestinationRuleChange: DestinationRuleChangeTrafficPolicy,
+			},
+			&PushRequest{
+				ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns1"}: {}},
+			},
+			PushRequest{
+				Reason: []TriggerReason{},
+				ConfigsUpdated: map[ConfigKey]struct{}{
+					{Kind: gvk.DestinationRule, Name: "dr1", Namespace: "ns1"}: {},
+					{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns1"}: {},
+				},
+			},
+		},
+		{
+			"combine service ports",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}, ServicePorts: map[ConfigKey][]int{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {80},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {80},
+			}},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}, ServicePorts: map[ConfigKey][]int{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {8080, 80},
+			}},
+			PushRequest{Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}, ServicePorts: map[ConfigKey][]int{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {80, 8080},
+			}},
+		},
+		{
+			"keep matching virtual service change kinds",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs1", Namespace: "ns1"}: {},
+			}, VirtualServiceChange: VirtualServiceChangeRouteSettings},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs2", Namespace: "ns1"}: {},
+			}, VirtualServiceChange: VirtualServiceChangeRouteSettings},
+			PushRequest{Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs1", Namespace: "ns1"}: {},
+				{Kind: gvk.VirtualService, Name: "vs2", Namespace: "ns1"}: {},
+			}, VirtualServiceChange: VirtualServiceChangeRouteSettings},
+		},
+		{
+			"drop differing virtual service change kinds",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs1", Namespace: "ns1"}: {},
+			}, VirtualServiceChange: VirtualServiceChangeRouteSettings},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs2", Namespace: "ns1"}: {},
+			}},
+			PushRequest{Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs1", Namespace: "ns1"}: {},
+				{Kind: gvk.VirtualService, Name: "vs2", Namespace: "ns1"}: {},
+			}},
+		},
+		{
+			"combine destination rule hosts",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}: {},
+			}, DestinationRuleHosts: map[ConfigKey][]host.Name{
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}: {"a.com"},
+			}},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}:  {},
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns1"}: {},
+			}, DestinationRuleHosts: map[ConfigKey][]host.Name{
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}:  {"a.com", "b.com"},
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns1"}: {"c.com"},
+			}},
+			PushRequest{Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}:  {},
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns1"}: {},
+			}, DestinationRuleHosts: map[ConfigKey][]host.Name{
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}:  {"a.com", "b.com"},
+				{Kind: gvk.DestinationRule, Name: "dr2", Namespace: "ns1"}: {"c.com"},
+			}},
+		},
+		{
+			"keep draining services drained by both",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}, DrainingServices: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+			}},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc3", Namespace: "ns1"}: {},
+			}, DrainingServices: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc3", Namespace: "ns1"}: {},
+			}},
+			PushRequest{Reason: []TriggerReason{}, ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc2", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc3", Namespace: "ns1"}: {},
+			}, DrainingServices: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+				{Kind: gvk.ServiceEntry, Name: "svc3", Namespace: "ns1"}: {},
+			}},
+		},
+		{
+			"drop draining services if either updates everything",
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+			}, DrainingServices: map[ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "svc1", Namespace: "ns1"}: {},
+			}},
+			&PushRequest{},
+			PushRequest{Reason: []TriggerReason{}},
+		},
 		{
 			"skip config type merge: one empty",
 			&PushRequest{Full: true, ConfigsUpdated: nil},
@@ -128,6 +412,54 @@ func TestMergeUpdateRequest(t *testing.T) {
 	}
 }
 
+func TestPushRequestFingerprint(t *testing.T) {
+	newRequest := func() *PushRequest {
+		return &PushRequest{
+			Full: true,
+			ConfigsUpdated: map[ConfigKey]struct{}{
+				{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}:  {},
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns2"}: {},
+			},
+			Reason: []TriggerReason{ConfigUpdate},
+		}
+	}
+
+	equal := newRequest()
+	equal.Start = time.Now()
+	equal.CorrelationID = "other"
+	if newRequest().Fingerprint() != equal.Fingerprint() {
+		t.Fatalf("expected equal requests to have the same fingerprint")
+	}
+
+	incremental := newRequest()
+	incremental.Full = false
+	otherNamespace := newRequest()
+	otherNamespace.ConfigsUpdated = map[ConfigKey]struct{}{
+		{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns2"}:  {},
+		{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}: {},
+	}
+	otherKind := newRequest()
+	otherKind.ConfigsUpdated = map[ConfigKey]struct{}{
+		{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns1"}: {},
+		{Kind: gvk.ServiceEntry, Name: "dr", Namespace: "ns2"}:   {},
+	}
+	allConfigs := newRequest()
+	allConfigs.ConfigsUpdated = nil
+	oneCluster := newRequest()
+	oneCluster.ClusterIDs = map[string]struct{}{"cluster-a": {}}
+	for name, req := range map[string]*PushRequest{
+		"incremental":     incremental,
+		"other namespace": otherNamespace,
+		"other kind":      otherKind,
+		"all configs":     allConfigs,
+		"one cluster":     oneCluster,
+	} {
+		if newRequest().Fingerprint() == req.Fingerprint() {
+			t.Errorf("%s: expected different requests to have different fingerprints", name)
+		}
+	}
+}
+
 func TestConcurrentMerge(t *testing.T) {
 	reqA := &PushRequest{Reason: make([]TriggerReason, 0, 100)}
 	reqB := &PushRequest{Reason: []TriggerReason{ServiceUpdate, ProxyUpdate}}
@@ -1348,6 +1680,99 @@ func TestVirtualServiceWithExportTo(t *testing.T) {
 	}
 }
 
+func TestGatewayVirtualServicesConfigKey(t *testing.T) {
+	ps := NewPushContext()
+	env := &Environment{Watcher: mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "zzz"})}
+	ps.Mesh = env.Mesh()
+	ps.ServiceDiscovery = env
+	configStore := NewFakeStore()
+	gatewayName := "gw-ns/gateway"
+
+	public := config.Config{
+		Meta: config.Meta{
+			Name:             "public",
+			Namespace:        "test1",
+			GroupVersionKind: gvk.VirtualService,
+		},
+		Spec: &networking.VirtualService{
+			Gateways: []string{gatewayName},
+			Hosts:    []string{"public.com"},
+			ExportTo: []string{"*"},
+		},
+	}
+	private := config.Config{
+		Meta: config.Meta{
+			Name:             "private",
+			Namespace:        "test2",
+			GroupVersionKind: gvk.VirtualService,
+		},
+		Spec: &networking.VirtualService{
+			Gateways: []string{gatewayName},
+			Hosts:    []string{"private.com"},
+			ExportTo: []string{"."},
+		},
+	}
+	otherGateway := config.Config{
+		Meta: config.Meta{
+			Name:             "other",
+			Namespace:        "test1",
+			GroupVersionKind: gvk.VirtualService,
+		},
+		Spec: &networking.VirtualService{
+			Gateways: []string{"gw-ns/other"},
+			Hosts:    []string{"other.com"},
+			ExportTo: []string{"*"},
+		},
+	}
+
+	// The servers of the gateway do not allow hosts from the namespace of this virtual service.
+	disallowed := config.Config{
+		Meta: config.Meta{
+			Name:             "disallowed",
+			Namespace:        "test3",
+			GroupVersionKind: gvk.VirtualService,
+		},
+		Spec: &networking.VirtualService{
+			Gateways: []string{gatewayName},
+			Hosts:    []string{"public.com"},
+			ExportTo: []string{"*"},
+		},
+	}
+
+	for _, c := range []config.Config{public, private, otherGateway, disallowed} {
+		if _, err := configStore.Create(c); err != nil {
+			t.Fatalf("could not create %v", c.Name)
+		}
+	}
+
+	store := istioConfigStore{ConfigStore: configStore}
+	env.IstioConfigStore = &store
+	ps.initDefaultExportMaps()
+	if err := ps.initVirtualServices(env); err != nil {
+		t.Fatalf("init virtual services failed: %v", err)
+	}
+
+	proxy := &Proxy{
+		Type:            Router,
+		ConfigNamespace: "gw-ns",
+		MergedGateway: &MergedGateway{
+			GatewayNameForServer: map[*networking.Server]string{
+				{Hosts: []string{"test1/*"}}:                          gatewayName,
+				{Hosts: []string{"test2/private.com", "*/other.com"}}: gatewayName,
+			},
+		},
+	}
+	got := ps.GatewayVirtualServicesConfigKey(proxy)
+	want := []ConfigKey{{Kind: gvk.VirtualService, Name: "public", Namespace: "test1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	if got := ps.GatewayVirtualServicesConfigKey(&Proxy{Type: Router, ConfigNamespace: "gw-ns"}); got != nil {
+		t.Errorf("expected no dependencies without merged gateway, got %+v", got)
+	}
+}
+
 func TestInitVirtualService(t *testing.T) {
 	ps := NewPushContext()
 	env := &Environment{Watcher: mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-system"})}
@@ -1682,3 +2107,239 @@ func (l *localServiceDiscovery) NetworkGateways() map[string][]*Gateway {
 	// TODO implement fromRegistry logic from kube controller if needed
 	return nil
 }
+
+func TestAuthenticationPolicyAffectsWorkload(t *testing.T) {
+	peerAuthn := func(name, namespace string, selector map[string]string) config.Config {
+		spec := &securityBeta.PeerAuthentication{
+			PortLevelMtls: map[uint32]*securityBeta.PeerAuthentication_MutualTLS{
+				8080: {Mode: securityBeta.PeerAuthentication_MutualTLS_STRICT},
+			},
+		}
+		if selector != nil {
+			spec.Selector = &selectorpb.WorkloadSelector{MatchLabels: selector}
+		}
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.PeerAuthentication, Name: name, Namespace: namespace},
+			Spec: spec,
+		}
+	}
+	requestAuthn := func(name, namespace string, selector map[string]string) config.Config {
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.RequestAuthentication, Name: name, Namespace: namespace},
+			Spec: &securityBeta.RequestAuthentication{Selector: &selectorpb.WorkloadSelector{MatchLabels: selector}},
+		}
+	}
+	policies := func(configs ...config.Config) *AuthenticationPolicies {
+		policy := &AuthenticationPolicies{
+			peerAuthentications:    map[string][]config.Config{},
+			requestAuthentications: map[string][]config.Config{},
+			rootNamespace:          "istio-system",
+		}
+		for _, cfg := range configs {
+			if cfg.GroupVersionKind == gvk.RequestAuthentication {
+				policy.requestAuthentications[cfg.Namespace] = append(policy.requestAuthentications[cfg.Namespace], cfg)
+			} else {
+				policy.peerAuthentications[cfg.Namespace] = append(policy.peerAuthentications[cfg.Namespace], cfg)
+			}
+		}
+		return policy
+	}
+
+	ps := &PushContext{
+		prevAuthnPolicies: policies(
+			peerAuthn("moved", "ns", map[string]string{"app": "foo"}),
+			peerAuthn("deleted", "ns", map[string]string{"app": "foo"}),
+			peerAuthn("namespace", "ns", nil),
+			requestAuthn("jwt", "ns", map[string]string{"app": "foo"}),
+		),
+		AuthnPolicies: policies(
+			peerAuthn("moved", "ns", map[string]string{"app": "bar"}),
+			peerAuthn("added", "ns", map[string]string{"app": "bar"}),
+			peerAuthn("root", "istio-system", map[string]string{"app": "bar"}),
+			peerAuthn("namespace", "ns", nil),
+			requestAuthn("jwt", "ns", map[string]string{"app": "bar"}),
+		),
+	}
+
+	cases := []struct {
+		name      string
+		kind      config.GroupVersionKind
+		policy    string
+		namespace string
+		labels    map[string]string
+		want      bool
+	}{
+		{"previously selected", gvk.PeerAuthentication, "moved", "ns", map[string]string{"app": "foo"}, true},
+		{"newly selected", gvk.PeerAuthentication, "moved", "ns", map[string]string{"app": "bar"}, true},
+		{"never selected", gvk.PeerAuthentication, "moved", "ns", map[string]string{"app": "baz"}, false},
+		{"other namespace", gvk.PeerAuthentication, "moved", "other", map[string]string{"app": "bar"}, false},
+		{"deleted policy", gvk.PeerAuthentication, "deleted", "ns", map[string]string{"app": "foo"}, true},
+		{"deleted policy not selected", gvk.PeerAuthentication, "deleted", "ns", map[string]string{"app": "bar"}, false},
+		{"added policy", gvk.PeerAuthentication, "added", "ns", map[string]string{"app": "bar"}, true},
+		{"root namespace policy", gvk.PeerAuthentication, "root", "other", map[string]string{"app": "bar"}, true},
+		{"namespace policy", gvk.PeerAuthentication, "namespace", "other", map[string]string{"app": "baz"}, true},
+		{"unknown policy", gvk.PeerAuthentication, "unknown", "ns", map[string]string{"app": "baz"}, true},
+		{"request authentication previously selected", gvk.RequestAuthentication, "jwt", "ns", map[string]string{"app": "foo"}, true},
+		{"request authentication never selected", gvk.RequestAuthentication, "jwt", "ns", map[string]string{"app": "baz"}, false},
+		{"unknown request authentication", gvk.RequestAuthentication, "moved", "ns", map[string]string{"app": "baz"}, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			key := ConfigKey{Kind: tt.kind, Name: tt.policy, Namespace: "ns"}
+			if tt.policy == "root" {
+				key.Namespace = "istio-system"
+			}
+			if got := ps.AuthenticationPolicyAffectsWorkload(key, tt.namespace, labels.Collection{tt.labels}); got != tt.want {
+				t.Fatalf("AuthenticationPolicyAffectsWorkload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestinationRuleExportedNamespaces(t *testing.T) {
+	rule := func(namespace string, exportTo ...string) config.Config {
+		return config.Config{
+			Meta: config.Meta{Name: "dr", Namespace: namespace},
+			Spec: &networking.DestinationRule{Host: "svc.ns1.svc.cluster.local", ExportTo: exportTo},
+		}
+	}
+	cases := []struct {
+		name    string
+		configs []config.Config
+		want    map[string]struct{}
+	}{
+		{
+			name:    "local",
+			configs: []config.Config{rule("ns1", ".")},
+			want:    map[string]struct{}{"ns1": {}},
+		},
+		{
+			name:    "all",
+			configs: []config.Config{rule("ns1", "*")},
+			want:    nil,
+		},
+		{
+			name:    "specific namespace",
+			configs: []config.Config{rule("ns1", "ns2")},
+			want:    map[string]struct{}{"ns2": {}},
+		},
+		{
+			name:    "no exportTo",
+			configs: []config.Config{rule("ns1")},
+			want:    nil,
+		},
+		{
+			name:    "changed from local to specific namespace",
+			configs: []config.Config{rule("ns1", "."), rule("ns1", "ns2")},
+			want:    map[string]struct{}{"ns1": {}, "ns2": {}},
+		},
+		{
+			name:    "added",
+			configs: []config.Config{{}, rule("ns1", ".", "ns3")},
+			want:    map[string]struct{}{"ns1": {}, "ns3": {}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DestinationRuleExportedNamespaces(tt.configs...); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DestinationRuleExportedNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestinationRuleHosts(t *testing.T) {
+	rule := func(hostname string) config.Config {
+		return config.Config{
+			Meta: config.Meta{Name: "dr", Namespace: "ns1", Domain: "cluster.local"},
+			Spec: &networking.DestinationRule{Host: hostname},
+		}
+	}
+	cases := []struct {
+		name    string
+		configs []config.Config
+		want    []host.Name
+	}{
+		{"fqdn", []config.Config{rule("svc.ns2.svc.cluster.local")}, []host.Name{"svc.ns2.svc.cluster.local"}},
+		{"short name", []config.Config{rule("svc")}, []host.Name{"svc.ns1.svc.cluster.local"}},
+		{"host unchanged", []config.Config{rule("svc"), rule("svc.ns1.svc.cluster.local")}, []host.Name{"svc.ns1.svc.cluster.local"}},
+		{"host changed", []config.Config{rule("a.com"), rule("b.com")}, []host.Name{"a.com", "b.com"}},
+		{"added", []config.Config{{}, rule("a.com")}, []host.Name{"a.com"}},
+		{"not a destination rule", []config.Config{{}}, nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DestinationRuleHosts(tt.configs...); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DestinationRuleHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyDestinationRuleChange(t *testing.T) {
+	subset := func(name, version string, policy *networking.TrafficPolicy) *networking.Subset {
+		return &networking.Subset{Name: name, Labels: map[string]string{"version": version}, TrafficPolicy: policy}
+	}
+	rule := func(policy *networking.TrafficPolicy, subsets ...*networking.Subset) config.Config {
+		return config.Config{
+			Meta: config.Meta{Name: "dr", Namespace: "ns1"},
+			Spec: &networking.DestinationRule{Host: "svc.ns1.svc.cluster.local", TrafficPolicy: policy, Subsets: subsets},
+		}
+	}
+	simple := func(lb networking.LoadBalancerSettings_SimpleLB) *networking.TrafficPolicy {
+		return &networking.TrafficPolicy{LoadBalancer: &networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: lb},
+		}}
+	}
+	hash := func(header string) *networking.TrafficPolicy {
+		return &networking.TrafficPolicy{LoadBalancer: &networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+				HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpHeaderName{HttpHeaderName: header},
+			}},
+		}}
+	}
+	cases := []struct {
+		name string
+		old  config.Config
+		curr config.Config
+		want DestinationRuleChangeKind
+	}{
+		{
+			name: "subset added",
+			old:  rule(nil, subset("v1", "v1", nil)),
+			curr: rule(nil, subset("v1", "v1", nil), subset("v2", "v2", nil)),
+			want: DestinationRuleChangeStructural,
+		},
+		{
+			name: "traffic policy changed",
+			old:  rule(simple(networking.LoadBalancerSettings_ROUND_ROBIN), subset("v1", "v1", nil)),
+			curr: rule(simple(networking.LoadBalancerSettings_LEAST_CONN), subset("v1", "v1", nil)),
+			want: DestinationRuleChangeTrafficPolicy,
+		},
+		{
+			name: "subset traffic policy and labels changed",
+			old:  rule(nil, subset("v1", "v1", nil)),
+			curr: rule(nil, subset("v1", "v1.1", simple(networking.LoadBalancerSettings_RANDOM))),
+			want: DestinationRuleChangeTrafficPolicy,
+		},
+		{
+			name: "consistent hash changed",
+			old:  rule(hash("x-user"), subset("v1", "v1", nil)),
+			curr: rule(hash("x-session"), subset("v1", "v1", nil)),
+			want: DestinationRuleChangeStructural,
+		},
+		{
+			name: "created",
+			old:  config.Config{},
+			curr: rule(nil, subset("v1", "v1", nil)),
+			want: DestinationRuleChangeStructural,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyDestinationRuleChange(tt.old, tt.curr); got != tt.want {
+				t.Fatalf("ClassifyDestinationRuleChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
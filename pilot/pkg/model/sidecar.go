@@ -48,6 +48,12 @@ var (
 		gvk.AuthorizationPolicy:   {},
 		gvk.RequestAuthentication: {},
 	}
+
+	// meshWideConfigTypes includes configs that commonly apply to all services when in root namespace,
+	// such as destination rules for wildcard hosts, so their changes are not matched by name.
+	meshWideConfigTypes = map[config.GroupVersionKind]struct{}{
+		gvk.DestinationRule: {},
+	}
 )
 
 // SidecarScope is a wrapper over the Sidecar resource with some
@@ -448,6 +454,69 @@ func (sc *SidecarScope) Services() []*Service {
 	return sc.services
 }
 
+// DependsOnServicePort returns whether sidecars using this scope import the port of the service with the
+// hostname. Egress listeners with a port only import that port of the services they select. A nil scope is
+// considered to import all ports of all services.
+func (sc *SidecarScope) DependsOnServicePort(hostname host.Name, port int) bool {
+	if sc == nil {
+		return true
+	}
+	s, f := sc.servicesByHostname[hostname]
+	if !f {
+		return false
+	}
+	for _, p := range s.Ports {
+		if p.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// DependsOnHost returns whether sidecars using this scope import a service with the hostname, or any service
+// it matches if it is a wildcard. A nil scope is considered to import all services.
+func (sc *SidecarScope) DependsOnHost(hostname host.Name) bool {
+	if sc == nil {
+		return true
+	}
+	if !hostname.IsWildCarded() {
+		_, f := sc.servicesByHostname[hostname]
+		return f
+	}
+	for _, s := range sc.services {
+		if hostname.Matches(s.Hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasHTTPServices returns whether sidecars using this scope may have any outbound HTTP
+// routes, that is, whether any imported service port or egress listener port is HTTP or
+// has its protocol sniffed. A nil scope is considered to have HTTP services.
+func (sc *SidecarScope) HasHTTPServices() bool {
+	if sc == nil {
+		return true
+	}
+
+	for _, el := range sc.EgressListeners {
+		if el.IstioListener != nil && el.IstioListener.Port != nil {
+			p := protocol.Parse(el.IstioListener.Port.Protocol)
+			if p.IsHTTP() || p.IsUnsupported() {
+				return true
+			}
+		}
+	}
+	for _, s := range sc.services {
+		for _, port := range s.Ports {
+			if port.Protocol.IsHTTP() || port.Protocol.IsUnsupported() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // DestinationRule returns the destination rule applicable for a given hostname
 // used by CDS code
 func (sc *SidecarScope) DestinationRule(hostname host.Name) *config.Config {
@@ -515,13 +584,45 @@ func (sc *SidecarScope) DependsOnConfig(config ConfigKey) bool {
 		return config.Namespace == sc.RootNamespace || config.Namespace == sc.Namespace
 	}
 
+	// This kind of config may apply to any service if made in the root namespace
+	if _, f := meshWideConfigTypes[config.Kind]; f && sc.RootNamespace != "" && config.Namespace == sc.RootNamespace {
+		return true
+	}
+
 	// This kind of config is unknown to sidecarScope.
 	if _, f := sidecarScopeKnownConfigTypes[config.Kind]; !f {
 		return true
 	}
 
-	_, exists := sc.configDependencies[config.HashCode()]
-	return exists
+	if _, exists := sc.configDependencies[config.HashCode()]; exists {
+		return true
+	}
+
+	// A ServiceEntry with a wildcard host changes how requests to any of its subdomains are routed, so the
+	// scope depends on it if it depends on any service it matches, whichever namespace declares it.
+	if config.Kind == gvk.ServiceEntry {
+		if wildcard := host.Name(config.Name); wildcard.IsWildCarded() {
+			for _, s := range sc.services {
+				if wildcard.Matches(s.Hostname) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RegisteredPushScopeKinds returns, sorted by name, the kinds whose changes a SidecarScope matches against the
+// configs it depends on. Changes of other kinds are either matched by namespace or affect all proxies.
+func RegisteredPushScopeKinds() []config.GroupVersionKind {
+	kinds := make([]config.GroupVersionKind, 0, len(sidecarScopeKnownConfigTypes))
+	for kind := range sidecarScopeKnownConfigTypes {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return kinds[i].String() < kinds[j].String()
+	})
+	return kinds
 }
 
 // AddConfigDependencies add extra config dependencies to this scope. This action should be done before the
@@ -27,6 +27,7 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
@@ -1357,6 +1358,151 @@ func TestRootNsSidecarDependencies(t *testing.T) {
 	}
 }
 
+func TestWildcardServiceEntryDependencies(t *testing.T) {
+	ps := NewPushContext()
+	meshConfig := mesh.DefaultMeshConfig()
+	ps.Mesh = &meshConfig
+	ps.ServiceIndex.public = append(ps.ServiceIndex.public,
+		&Service{Hostname: "a.foo.com", Attributes: ServiceAttributes{Namespace: "ns1"}},
+		&Service{Hostname: "bar.com", Attributes: ServiceAttributes{Namespace: "ns1"}},
+	)
+	sidecarScope := DefaultSidecarScopeForNamespace(ps, "default")
+
+	cases := []struct {
+		name   string
+		config ConfigKey
+		want   bool
+	}{
+		{"wildcard matching dependent host", ConfigKey{gvk.ServiceEntry, "*.foo.com", "ns1"}, true},
+		{"wildcard declared in other namespace", ConfigKey{gvk.ServiceEntry, "*.foo.com", "ns2"}, true},
+		{"wider wildcard", ConfigKey{gvk.ServiceEntry, "*.com", "ns1"}, true},
+		{"wildcard matching no dependent host", ConfigKey{gvk.ServiceEntry, "*.baz.com", "ns1"}, false},
+		{"wildcard matching only parent domain", ConfigKey{gvk.ServiceEntry, "*.a.foo.com", "ns1"}, false},
+		{"specific host", ConfigKey{gvk.ServiceEntry, "b.foo.com", "ns1"}, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sidecarScope.DependsOnConfig(tt.config); got != tt.want {
+				t.Fatalf("DependsOnConfig(%v) = %v, want %v", tt.config, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootNamespaceDestinationRuleDependencies(t *testing.T) {
+	ps := NewPushContext()
+	meshConfig := mesh.DefaultMeshConfig()
+	ps.Mesh = &meshConfig
+	ps.ServiceIndex.public = append(ps.ServiceIndex.public,
+		&Service{Hostname: "svc.ns1.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "ns1"}},
+	)
+	sidecarScope := DefaultSidecarScopeForNamespace(ps, "default")
+
+	cases := []struct {
+		name   string
+		config ConfigKey
+		want   bool
+	}{
+		{"root namespace", ConfigKey{gvk.DestinationRule, "mesh-wide", meshConfig.RootNamespace}, true},
+		{"regular namespace", ConfigKey{gvk.DestinationRule, "dr", "ns1"}, false},
+		{"other kind in root namespace", ConfigKey{gvk.VirtualService, "vs", meshConfig.RootNamespace}, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sidecarScope.DependsOnConfig(tt.config); got != tt.want {
+				t.Fatalf("DependsOnConfig(%v) = %v, want %v", tt.config, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSidecarScopeDependsOnServicePort(t *testing.T) {
+	ps := NewPushContext()
+	meshConfig := mesh.DefaultMeshConfig()
+	ps.Mesh = &meshConfig
+	ps.ServiceIndex.public = append(ps.ServiceIndex.public, &Service{
+		Hostname:   "svc.ns1.svc.cluster.local",
+		Ports:      PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}, {Name: "tcp", Port: 8080, Protocol: protocol.TCP}},
+		Attributes: ServiceAttributes{Namespace: "ns1"},
+	})
+	cfg := &config.Config{
+		Meta: config.Meta{Name: "sidecar", Namespace: "default"},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{
+				Port:  &networking.Port{Number: 9080, Protocol: "HTTP", Name: "http"},
+				Hosts: []string{"ns1/*"},
+			}},
+		},
+	}
+	portScope := ConvertToSidecarScope(ps, cfg, "default")
+	defaultScope := DefaultSidecarScopeForNamespace(ps, "default")
+
+	cases := []struct {
+		name     string
+		scope    *SidecarScope
+		hostname host.Name
+		port     int
+		want     bool
+	}{
+		{"imported port", portScope, "svc.ns1.svc.cluster.local", 9080, true},
+		{"port not imported", portScope, "svc.ns1.svc.cluster.local", 8080, false},
+		{"service not imported", portScope, "other.ns1.svc.cluster.local", 9080, false},
+		{"all ports imported", defaultScope, "svc.ns1.svc.cluster.local", 8080, true},
+		{"nil scope", nil, "svc.ns1.svc.cluster.local", 8080, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.DependsOnServicePort(tt.hostname, tt.port); got != tt.want {
+				t.Fatalf("DependsOnServicePort(%v, %d) = %v, want %v", tt.hostname, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSidecarScopeDependsOnHost(t *testing.T) {
+	ps := NewPushContext()
+	meshConfig := mesh.DefaultMeshConfig()
+	ps.Mesh = &meshConfig
+	ps.ServiceIndex.public = append(ps.ServiceIndex.public, &Service{
+		Hostname:   "svc.ns1.svc.cluster.local",
+		Ports:      PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}},
+		Attributes: ServiceAttributes{Namespace: "ns1"},
+	})
+	scope := DefaultSidecarScopeForNamespace(ps, "default")
+
+	cases := []struct {
+		name     string
+		scope    *SidecarScope
+		hostname host.Name
+		want     bool
+	}{
+		{"imported service", scope, "svc.ns1.svc.cluster.local", true},
+		{"service not imported", scope, "other.ns1.svc.cluster.local", false},
+		{"wildcard matching imported service", scope, "*.ns1.svc.cluster.local", true},
+		{"wildcard matching no service", scope, "*.ns2.svc.cluster.local", false},
+		{"nil scope", nil, "other.ns1.svc.cluster.local", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.DependsOnHost(tt.hostname); got != tt.want {
+				t.Fatalf("DependsOnHost(%v) = %v, want %v", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredPushScopeKinds(t *testing.T) {
+	got := map[config.GroupVersionKind]struct{}{}
+	for _, kind := range RegisteredPushScopeKinds() {
+		got[kind] = struct{}{}
+	}
+	for _, kind := range []config.GroupVersionKind{gvk.ServiceEntry, gvk.VirtualService, gvk.DestinationRule} {
+		if _, f := got[kind]; !f {
+			t.Errorf("expected %v to be registered, got %v", kind, RegisteredPushScopeKinds())
+		}
+	}
+}
+
 func TestSidecarOutboundTrafficPolicy(t *testing.T) {
 	configWithoutOutboundTrafficPolicy := &config.Config{
 		Meta: config.Meta{
@@ -1487,3 +1633,39 @@ outboundTrafficPolicy:
 		})
 	}
 }
+
+func TestSidecarScopeHasHTTPServices(t *testing.T) {
+	tcpService := &Service{Hostname: "tcp.com", Ports: PortList{{Name: "tcp", Port: 9000, Protocol: protocol.TCP}}}
+	httpService := &Service{Hostname: "http.com", Ports: PortList{{Name: "http", Port: 80, Protocol: protocol.HTTP}}}
+	sniffedService := &Service{Hostname: "auto.com", Ports: PortList{{Name: "auto", Port: 8080, Protocol: protocol.Unsupported}}}
+
+	cases := []struct {
+		name  string
+		scope *SidecarScope
+		want  bool
+	}{
+		{"nil scope", nil, true},
+		{"tcp only", &SidecarScope{services: []*Service{tcpService}}, false},
+		{"http", &SidecarScope{services: []*Service{tcpService, httpService}}, true},
+		{"sniffed protocol", &SidecarScope{services: []*Service{sniffedService}}, true},
+		{
+			"http egress listener",
+			&SidecarScope{
+				services: []*Service{tcpService},
+				EgressListeners: []*IstioEgressListenerWrapper{{
+					IstioListener: &networking.IstioEgressListener{
+						Port: &networking.Port{Number: 8000, Protocol: "HTTP", Name: "http"},
+					},
+				}},
+			},
+			true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.HasHTTPServices(); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
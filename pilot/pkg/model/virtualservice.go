@@ -18,6 +18,7 @@ import (
 	"strings"
 
 	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
@@ -467,3 +468,45 @@ func isRootVs(vs *networking.VirtualService) bool {
 	}
 	return false
 }
+
+// VirtualServiceChangeKind classifies a VirtualService change by the parts of the configuration it affects,
+// so that listeners are only pushed when they may change.
+type VirtualServiceChangeKind int
+
+const (
+	// VirtualServiceChangeStructural is a change to the hosts, gateways, matches or destinations of a virtual
+	// service, or to its TCP or TLS routes, affecting listeners as well as routes. This is the default when
+	// nothing more specific is known.
+	VirtualServiceChangeStructural VirtualServiceChangeKind = iota
+	// VirtualServiceChangeRouteSettings is a change to the headers, retries or timeouts of otherwise unchanged
+	// HTTP routes. It affects routes only.
+	VirtualServiceChangeRouteSettings
+)
+
+// ClassifyVirtualServiceChange returns the kind of change between two versions of a VirtualService.
+func ClassifyVirtualServiceChange(old, curr config.Config) VirtualServiceChangeKind {
+	o, ok := old.Spec.(*networking.VirtualService)
+	if !ok {
+		return VirtualServiceChangeStructural
+	}
+	n, ok := curr.Spec.(*networking.VirtualService)
+	if !ok {
+		return VirtualServiceChangeStructural
+	}
+	if !proto.Equal(virtualServiceListenerSettings(o), virtualServiceListenerSettings(n)) {
+		return VirtualServiceChangeStructural
+	}
+	return VirtualServiceChangeRouteSettings
+}
+
+// virtualServiceListenerSettings returns a copy of the virtual service without the settings of its HTTP
+// routes that only affect routes.
+func virtualServiceListenerSettings(vs *networking.VirtualService) *networking.VirtualService {
+	out := proto.Clone(vs).(*networking.VirtualService)
+	for _, route := range out.Http {
+		route.Headers = nil
+		route.Retries = nil
+		route.Timeout = nil
+	}
+	return out
+}
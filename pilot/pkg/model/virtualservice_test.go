@@ -26,6 +26,7 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/visibility"
 )
 
@@ -623,6 +624,43 @@ func TestMergeVirtualServices(t *testing.T) {
 	}
 }
 
+func TestMergeVirtualServicesDelegationCycle(t *testing.T) {
+	vs := func(name string, hosts []string, delegate string) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+				Name:             name,
+				Namespace:        "default",
+			},
+			Spec: &networking.VirtualService{
+				Hosts:    hosts,
+				Gateways: []string{"gateway"},
+				Http: []*networking.HTTPRoute{{
+					Delegate: &networking.Delegate{Name: delegate, Namespace: "default"},
+				}},
+			},
+		}
+	}
+	key := func(name string) ConfigKey {
+		return ConfigKey{Kind: gvk.VirtualService, Name: name, Namespace: "default"}
+	}
+	// Delegation is only resolved one level deep, so neither a cycle between two virtual services nor one
+	// delegating to itself is followed. The roots still depend on the virtual services they delegate to.
+	_, delegates := mergeVirtualServicesIfNeeded([]config.Config{
+		vs("root", []string{"example.org"}, "delegate"),
+		vs("delegate", nil, "root"),
+		vs("self", []string{"example.com"}, "self"),
+	}, map[visibility.Instance]bool{visibility.Public: true})
+
+	want := map[ConfigKey][]ConfigKey{
+		key("root"): {key("delegate")},
+		key("self"): {key("self")},
+	}
+	if !reflect.DeepEqual(delegates, want) {
+		t.Fatalf("got delegates %v, want %v", delegates, want)
+	}
+}
+
 func TestMergeHttpRoutes(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -1787,3 +1825,67 @@ func BenchmarkResolveGatewayName(b *testing.B) {
 		}
 	}
 }
+
+func TestClassifyVirtualServiceChange(t *testing.T) {
+	vs := func(mutate func(route *networking.HTTPRoute)) config.Config {
+		route := &networking.HTTPRoute{
+			Match: []*networking.HTTPMatchRequest{{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/"}}}},
+			Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "svc.ns.svc.cluster.local"}}},
+		}
+		if mutate != nil {
+			mutate(route)
+		}
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "vs", Namespace: "ns"},
+			Spec: &networking.VirtualService{Hosts: []string{"svc.ns.svc.cluster.local"}, Http: []*networking.HTTPRoute{route}},
+		}
+	}
+	cases := []struct {
+		name string
+		old  config.Config
+		curr config.Config
+		want VirtualServiceChangeKind
+	}{
+		{
+			"response header added",
+			vs(nil),
+			vs(func(route *networking.HTTPRoute) {
+				route.Headers = &networking.Headers{Response: &networking.Headers_HeaderOperations{Add: map[string]string{"x-foo": "bar"}}}
+			}),
+			VirtualServiceChangeRouteSettings,
+		},
+		{
+			"retries and timeout changed",
+			vs(nil),
+			vs(func(route *networking.HTTPRoute) {
+				route.Retries = &networking.HTTPRetry{Attempts: 3}
+				route.Timeout = &types.Duration{Seconds: 10}
+			}),
+			VirtualServiceChangeRouteSettings,
+		},
+		{
+			"match added",
+			vs(nil),
+			vs(func(route *networking.HTTPRoute) {
+				route.Match = append(route.Match, &networking.HTTPMatchRequest{Port: 8080})
+			}),
+			VirtualServiceChangeStructural,
+		},
+		{
+			"destination changed",
+			vs(nil),
+			vs(func(route *networking.HTTPRoute) {
+				route.Route[0].Destination.Host = "other.ns.svc.cluster.local"
+			}),
+			VirtualServiceChangeStructural,
+		},
+		{"added", config.Config{}, vs(nil), VirtualServiceChangeStructural},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyVirtualServiceChange(tt.old, tt.curr); got != tt.want {
+				t.Fatalf("ClassifyVirtualServiceChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -266,6 +266,23 @@ func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) labels.Collectio
 	return out
 }
 
+// GetProxyNamespaceLabels returns the labels of the proxy's namespace from the first registry of its cluster that
+// knows them.
+func (c *Controller) GetProxyNamespaceLabels(proxy *model.Proxy) map[string]string {
+	nodeClusterID := nodeClusterID(proxy)
+	for _, r := range c.GetRegistries() {
+		if skipSearchingRegistryForProxy(nodeClusterID, r) {
+			continue
+		}
+		if getter, ok := r.(model.NamespaceLabelsGetter); ok {
+			if namespaceLabels := getter.GetProxyNamespaceLabels(proxy); namespaceLabels != nil {
+				return namespaceLabels
+			}
+		}
+	}
+	return nil
+}
+
 // Run starts all the controllers
 func (c *Controller) Run(stop <-chan struct{}) {
 	for _, r := range c.GetRegistries() {
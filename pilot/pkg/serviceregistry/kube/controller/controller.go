@@ -1200,6 +1200,15 @@ func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) labels.Collectio
 	return nil
 }
 
+// GetProxyNamespaceLabels returns the labels of the proxy's namespace, or nil if the namespace is unknown.
+func (c *Controller) GetProxyNamespaceLabels(proxy *model.Proxy) map[string]string {
+	ns, err := c.nsInformer.Lister().Get(proxy.ConfigNamespace)
+	if err != nil {
+		return nil
+	}
+	return ns.Labels
+}
+
 // GetIstioServiceAccounts returns the Istio service accounts running a service
 // hostname. Each service account is encoded according to the SPIFFE VSID spec.
 // For example, a service account named "bar" in namespace "foo" is encoded as
@@ -736,6 +736,24 @@ func TestGetProxyServiceInstancesWithMultiIPsAndTargetPorts(t *testing.T) {
 	}
 }
 
+func TestController_GetProxyNamespaceLabels(t *testing.T) {
+	controller, _ := NewFakeControllerWithOptions(FakeControllerOptions{})
+	defer controller.Stop()
+
+	namespaceLabels := map[string]string{"istio-injection": "enabled"}
+	createNamespace(t, controller.client, "nsa", namespaceLabels)
+
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := controller.GetProxyNamespaceLabels(&model.Proxy{ConfigNamespace: "nsa"}); !reflect.DeepEqual(got, namespaceLabels) {
+			return fmt.Errorf("GetProxyNamespaceLabels() = %v, want %v", got, namespaceLabels)
+		}
+		return nil
+	})
+	if got := controller.GetProxyNamespaceLabels(&model.Proxy{ConfigNamespace: "nsb"}); got != nil {
+		t.Errorf("GetProxyNamespaceLabels() = %v, want nil", got)
+	}
+}
+
 func TestController_GetIstioServiceAccounts(t *testing.T) {
 	oldTrustDomain := spiffe.GetTrustDomain()
 	spiffe.SetTrustDomain(defaultFakeDomainSuffix)
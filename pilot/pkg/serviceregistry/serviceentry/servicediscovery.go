@@ -17,6 +17,7 @@ package serviceentry
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -247,6 +248,8 @@ func getUpdatedConfigs(services []*model.Service) map[model.ConfigKey]struct{} {
 func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event model.Event) {
 	cs := convertServices(curr)
 	configsUpdated := map[model.ConfigKey]struct{}{}
+	// servicePorts are the changed ports of the updated services, where only ports changed.
+	servicePorts := map[model.ConfigKey][]int{}
 
 	// If it is add/delete event we should always do a full push. If it is update event, we should do full push,
 	// only when services have changed - otherwise, just push endpoint updates.
@@ -256,6 +259,11 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 	case model.EventUpdate:
 		os := convertServices(old)
 		if selectorChanged(old, curr) {
+			if onlySelectorChanged(os, cs) {
+				// The services themselves are unchanged, only the workloads backing them are.
+				s.workloadSelectorUpdate(old, curr, cs)
+				return
+			}
 			// Consider all services are updated.
 			mark := make(map[host.Name]*model.Service, len(cs))
 			for _, svc := range cs {
@@ -269,6 +277,15 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 			}
 		} else {
 			addedSvcs, deletedSvcs, updatedSvcs, unchangedSvcs = servicesDiff(os, cs)
+			oldSvcs := make(map[host.Name]*model.Service, len(os))
+			for _, svc := range os {
+				oldSvcs[svc.Hostname] = svc
+			}
+			for _, svc := range updatedSvcs {
+				if ports := changedServicePorts(oldSvcs[svc.Hostname], svc); ports != nil {
+					servicePorts[makeConfigKey(svc)] = ports
+				}
+			}
 		}
 	case model.EventDelete:
 		deletedSvcs = cs
@@ -282,6 +299,7 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 	for _, svc := range addedSvcs {
 		s.XdsUpdater.SvcUpdate(s.Cluster(), string(svc.Hostname), svc.Attributes.Namespace, model.EventAdd)
 		configsUpdated[makeConfigKey(svc)] = struct{}{}
+		servicePorts[makeConfigKey(svc)] = servicePortNumbers(svc)
 	}
 
 	for _, svc := range updatedSvcs {
@@ -293,6 +311,7 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 	for _, svc := range deletedSvcs {
 		s.XdsUpdater.SvcUpdate(s.Cluster(), string(svc.Hostname), svc.Attributes.Namespace, model.EventDelete)
 		configsUpdated[makeConfigKey(svc)] = struct{}{}
+		servicePorts[makeConfigKey(svc)] = servicePortNumbers(svc)
 	}
 
 	if len(unchangedSvcs) > 0 {
@@ -363,9 +382,100 @@ func (s *ServiceEntryStore) serviceEntryHandler(old, curr config.Config, event m
 		ConfigsUpdated: configsUpdated,
 		Reason:         []model.TriggerReason{model.ServiceUpdate},
 	}
+	for key, ports := range servicePorts {
+		// Services without changed ports are only updated along with others, such as unchanged DNS services
+		// whose endpoints changed, and may affect any port.
+		if _, f := configsUpdated[key]; f && len(ports) > 0 {
+			if pushReq.ServicePorts == nil {
+				pushReq.ServicePorts = map[model.ConfigKey][]int{}
+			}
+			pushReq.ServicePorts[key] = ports
+		}
+	}
 	s.XdsUpdater.ConfigUpdate(pushReq)
 }
 
+// workloadSelectorUpdate handles an update of a service entry whose workload selector is the only
+// change. The endpoints of its services are pushed via EDS, and the workloads that were added to or
+// removed from the selection are pushed directly, since their set of proxy service instances changed.
+func (s *ServiceEntryStore) workloadSelectorUpdate(old, curr config.Config, services []*model.Service) {
+	oldSelected := s.selectedWorkloads(old.Namespace, old.Spec.(*networking.ServiceEntry).WorkloadSelector)
+	currSelected := s.selectedWorkloads(curr.Namespace, curr.Spec.(*networking.ServiceEntry).WorkloadSelector)
+
+	s.storeMutex.Lock()
+	s.refreshIndexes.Store(true)
+	s.storeMutex.Unlock()
+
+	keys := make(map[instancesKey]struct{}, len(services))
+	for _, svc := range services {
+		keys[instancesKey{hostname: svc.Hostname, namespace: curr.Namespace}] = struct{}{}
+	}
+	s.edsUpdateByKeys(keys, true)
+
+	changed := make([]selectedWorkload, 0, len(oldSelected)+len(currSelected))
+	for w := range currSelected {
+		if _, f := oldSelected[w]; !f {
+			changed = append(changed, w)
+		}
+	}
+	for w := range oldSelected {
+		if _, f := currSelected[w]; !f {
+			changed = append(changed, w)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		if changed[i].clusterID != changed[j].clusterID {
+			return changed[i].clusterID < changed[j].clusterID
+		}
+		return changed[i].address < changed[j].address
+	})
+	for _, w := range changed {
+		s.XdsUpdater.ProxyUpdate(w.clusterID, w.address)
+	}
+}
+
+// selectedWorkload identifies the proxy of a workload selected by a service entry.
+type selectedWorkload struct {
+	// clusterID is the cluster of the proxy, as it reports it.
+	clusterID string
+	address   string
+}
+
+// selectedWorkloads returns the workload instances and workload entries in the namespace that are selected
+// by the workload selector. Workload instances are in the cluster of the registry they come from, workload
+// entries in the cluster of this registry.
+func (s *ServiceEntryStore) selectedWorkloads(namespace string, selector *networking.WorkloadSelector) map[selectedWorkload]struct{} {
+	out := map[selectedWorkload]struct{}{}
+	if selector == nil {
+		return out
+	}
+
+	s.storeMutex.RLock()
+	for _, wi := range s.workloadInstancesByIP {
+		if wi.Namespace != namespace {
+			continue
+		}
+		workloadLabels := labels.Collection{wi.Endpoint.Labels}
+		if workloadLabels.IsSupersetOf(selector.Labels) {
+			out[selectedWorkload{clusterID: wi.Endpoint.Locality.ClusterID, address: wi.Endpoint.Address}] = struct{}{}
+		}
+	}
+	s.storeMutex.RUnlock()
+
+	wles, err := s.store.List(gvk.WorkloadEntry, namespace)
+	if err != nil {
+		log.Errorf("Error listing workload entries: %v", err)
+	}
+	for _, wcfg := range wles {
+		wle := wcfg.Spec.(*networking.WorkloadEntry)
+		workloadLabels := labels.Collection{wle.Labels}
+		if workloadLabels.IsSupersetOf(selector.Labels) {
+			out[selectedWorkload{clusterID: s.Cluster(), address: wle.Address}] = struct{}{}
+		}
+	}
+	return out
+}
+
 // WorkloadInstanceHandler defines the handler for service instances generated by other registries
 func (s *ServiceEntryStore) WorkloadInstanceHandler(wi *model.WorkloadInstance, event model.Event) {
 	key := configKey{
@@ -627,6 +737,13 @@ func (s *ServiceEntryStore) edsUpdateByKeys(keys map[instancesKey]struct{}, push
 			})
 	}
 
+	// Services of the keys without instances left have no endpoints.
+	for k := range keys {
+		if _, f := endpoints[k]; !f {
+			endpoints[k] = nil
+		}
+	}
+
 	if push {
 		for k, eps := range endpoints {
 			s.XdsUpdater.EDSUpdate(s.Cluster(), string(k.hostname), k.namespace, eps)
@@ -829,6 +946,10 @@ func (s *ServiceEntryStore) NetworkGateways() map[string][]*model.Gateway {
 	return nil
 }
 
+// servicesDiff compares the services converted from the old and new service entries by hostname.
+// Any change to the service itself, such as the location (MeshExternal) flipping between MESH_INTERNAL
+// and MESH_EXTERNAL, is structural: it changes mTLS and cluster settings, so the service is reported as
+// updated and triggers a full push rather than an endpoint-only update.
 func servicesDiff(os []*model.Service, ns []*model.Service) ([]*model.Service, []*model.Service, []*model.Service, []*model.Service) {
 	var added, deleted, updated, unchanged []*model.Service
 
@@ -860,6 +981,40 @@ func servicesDiff(os []*model.Service, ns []*model.Service) ([]*model.Service, [
 	return added, deleted, updated, unchanged
 }
 
+// changedServicePorts returns the numbers of the ports that were added, removed or changed between the old and
+// new versions of a service, or nil if anything but its ports changed, which may affect any port.
+func changedServicePorts(old, curr *model.Service) []int {
+	if old == nil || curr == nil || old.AutoAllocatedAddress != curr.AutoAllocatedAddress {
+		return nil
+	}
+	o, c := old.DeepCopy(), curr.DeepCopy()
+	o.Ports, c.Ports = nil, nil
+	if !reflect.DeepEqual(o, c) {
+		return nil
+	}
+	var ports []int
+	for _, p := range curr.Ports {
+		if op, f := old.Ports.GetByPort(p.Port); !f || !reflect.DeepEqual(op, p) {
+			ports = append(ports, p.Port)
+		}
+	}
+	for _, p := range old.Ports {
+		if _, f := curr.Ports.GetByPort(p.Port); !f {
+			ports = append(ports, p.Port)
+		}
+	}
+	return ports
+}
+
+// servicePortNumbers returns the numbers of all ports of the service.
+func servicePortNumbers(svc *model.Service) []int {
+	ports := make([]int, 0, len(svc.Ports))
+	for _, p := range svc.Ports {
+		ports = append(ports, p.Port)
+	}
+	return ports
+}
+
 // This method compares if the selector on a service entry has changed, meaning that it needs full push.
 func selectorChanged(old, curr config.Config) bool {
 	o := old.Spec.(*networking.ServiceEntry)
@@ -867,6 +1022,30 @@ func selectorChanged(old, curr config.Config) bool {
 	return !reflect.DeepEqual(o.WorkloadSelector, n.WorkloadSelector)
 }
 
+// onlySelectorChanged returns true if the services converted from the old and new service entries
+// differ only in their label selectors. DNS services are excluded, as their workload endpoints are
+// sent in CDS and so need a full push.
+func onlySelectorChanged(os []*model.Service, ns []*model.Service) bool {
+	withoutSelectors := func(services []*model.Service) []*model.Service {
+		out := make([]*model.Service, 0, len(services))
+		for _, svc := range services {
+			if svc.Resolution == model.DNSLB {
+				return nil
+			}
+			c := svc.DeepCopy()
+			c.Attributes.LabelSelectors = nil
+			out = append(out, c)
+		}
+		return out
+	}
+	o, n := withoutSelectors(os), withoutSelectors(ns)
+	if len(o) != len(os) || len(n) != len(ns) {
+		return false
+	}
+	added, deleted, updated, _ := servicesDiff(o, n)
+	return len(added) == 0 && len(deleted) == 0 && len(updated) == 0
+}
+
 // Automatically allocates IPs for service entry services WITHOUT an
 // address field if the hostname is not a wildcard, or when resolution
 // is not NONE. The IPs are allocated from the reserved Class E subnet
@@ -74,8 +74,10 @@ type Event struct {
 	host      string
 	namespace string
 	proxyIP   string
-	endpoints int
-	pushReq   *model.PushRequest
+	// proxyCluster is the cluster of the proxy pushed with proxyIP.
+	proxyCluster string
+	endpoints    int
+	pushReq      *model.PushRequest
 }
 
 type FakeXdsUpdater struct {
@@ -96,8 +98,8 @@ func (fx *FakeXdsUpdater) ConfigUpdate(req *model.PushRequest) {
 	fx.Events <- Event{kind: "xds", pushReq: req}
 }
 
-func (fx *FakeXdsUpdater) ProxyUpdate(_, ip string) {
-	fx.Events <- Event{kind: "xds", proxyIP: ip}
+func (fx *FakeXdsUpdater) ProxyUpdate(clusterID, ip string) {
+	fx.Events <- Event{kind: "xds", proxyIP: ip, proxyCluster: clusterID}
 }
 
 func (fx *FakeXdsUpdater) SvcUpdate(_, hostname string, namespace string, event model.Event) {
@@ -477,14 +479,11 @@ func TestServiceDiscoveryServiceUpdate(t *testing.T) {
 			return &c
 		}()
 		createConfigs([]*config.Config{selector1Updated}, store, t)
+		// Only the selected workloads change, so we just need an EDS push
+		googleInstances := sd.InstancesByPort(convertServices(*selector1Updated)[0], 0, nil)
 		expectEvents(t, events,
-			Event{kind: "svcupdate", host: "*.google.com", namespace: httpStaticOverlay.Namespace},
-			Event{kind: "svcupdate", host: "selector1.com", namespace: httpStaticOverlay.Namespace},
-
-			Event{kind: "xds", pushReq: &model.PushRequest{ConfigsUpdated: map[model.ConfigKey]struct{}{
-				{Kind: gvk.ServiceEntry, Name: "*.google.com", Namespace: selector1.Namespace}:  {},
-				{Kind: gvk.ServiceEntry, Name: "selector1.com", Namespace: selector1.Namespace}: {},
-			}}}) // service updated
+			Event{kind: "eds", host: "*.google.com", namespace: httpStaticOverlay.Namespace, endpoints: len(googleInstances)},
+			Event{kind: "eds", host: "selector1.com", namespace: httpStaticOverlay.Namespace, endpoints: 0})
 	})
 }
 
@@ -759,6 +758,121 @@ func TestServiceDiscoveryWorkloadChangeLabel(t *testing.T) {
 	})
 }
 
+func TestServiceDiscoveryEndpointChange(t *testing.T) {
+	store, sd, events, stopFn := initServiceDiscovery()
+	defer stopFn()
+
+	// relabeled is the same as httpStaticOverlay, but its endpoint has different labels, changing the subsets it is in
+	relabeled := func() *config.Config {
+		c := httpStaticOverlay.DeepCopy()
+		se := c.Spec.(*networking.ServiceEntry)
+		se.Endpoints[0].Labels = map[string]string{"overlay": "baz"}
+		return &c
+	}()
+	// readdressed is the same as relabeled, but its endpoint has a different address
+	readdressed := func() *config.Config {
+		c := relabeled.DeepCopy()
+		se := c.Spec.(*networking.ServiceEntry)
+		se.Endpoints[0].Address = "6.6.6.6"
+		return &c
+	}()
+
+	createConfigs([]*config.Config{httpStaticOverlay}, store, t)
+	expectEvents(t, events,
+		Event{kind: "svcupdate", host: "*.google.com", namespace: httpStaticOverlay.Namespace},
+		Event{kind: "xds", pushReq: &model.PushRequest{ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: httpStaticOverlay.Spec.(*networking.ServiceEntry).Hosts[0], Namespace: httpStaticOverlay.Namespace}: {}}}})
+
+	t.Run("label change", func(t *testing.T) {
+		// Only the subsets the endpoint is in change, which EDS recomputes, so no full push is needed.
+		createConfigs([]*config.Config{relabeled}, store, t)
+		instances := []*model.ServiceInstance{
+			makeInstance(relabeled, "5.5.5.5", 4567, relabeled.Spec.(*networking.ServiceEntry).Ports[0], map[string]string{"overlay": "baz"}, PlainText),
+		}
+		expectServiceInstances(t, sd, relabeled, 0, instances)
+		expectEvents(t, events, Event{kind: "eds", host: "*.google.com", namespace: relabeled.Namespace, endpoints: 1})
+	})
+
+	t.Run("address change", func(t *testing.T) {
+		createConfigs([]*config.Config{readdressed}, store, t)
+		instances := []*model.ServiceInstance{
+			makeInstance(readdressed, "6.6.6.6", 4567, readdressed.Spec.(*networking.ServiceEntry).Ports[0], map[string]string{"overlay": "baz"}, PlainText),
+		}
+		expectServiceInstances(t, sd, readdressed, 0, instances)
+		expectEvents(t, events, Event{kind: "eds", host: "*.google.com", namespace: readdressed.Namespace, endpoints: 1})
+	})
+}
+
+func TestServiceDiscoveryServicePorts(t *testing.T) {
+	store, _, events, stopFn := initServiceDiscovery()
+	defer stopFn()
+
+	se := &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind:  gvk.ServiceEntry,
+			Name:              "ports",
+			Namespace:         "ports",
+			CreationTimestamp: GlobalTime,
+		},
+		Spec: &networking.ServiceEntry{
+			Hosts: []string{"ports.com"},
+			Ports: []*networking.Port{
+				{Number: 80, Name: "http-port", Protocol: "http"},
+				{Number: 8080, Name: "http-alt-port", Protocol: "http"},
+			},
+			Endpoints:  []*networking.WorkloadEntry{{Address: "2.2.2.2"}},
+			Location:   networking.ServiceEntry_MESH_EXTERNAL,
+			Resolution: networking.ServiceEntry_STATIC,
+		},
+	}
+	key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "ports.com", Namespace: "ports"}
+	modify := func(f func(se *networking.ServiceEntry)) *config.Config {
+		c := se.DeepCopy()
+		f(c.Spec.(*networking.ServiceEntry))
+		return &c
+	}
+	pushed := func() *model.PushRequest {
+		t.Helper()
+		for {
+			if e := waitForEvent(t, events); e.kind == "xds" {
+				return e.pushReq
+			}
+		}
+	}
+
+	cases := []struct {
+		name string
+		cfg  *config.Config
+		want map[model.ConfigKey][]int
+	}{
+		{"service added", se, map[model.ConfigKey][]int{key: {80, 8080}}},
+		{"port added", modify(func(se *networking.ServiceEntry) {
+			se.Ports = append(se.Ports, &networking.Port{Number: 9090, Name: "grpc-port", Protocol: "grpc"})
+		}), map[model.ConfigKey][]int{key: {9090}}},
+		{"port changed", modify(func(se *networking.ServiceEntry) {
+			se.Ports[1] = &networking.Port{Number: 8080, Name: "tcp-alt-port", Protocol: "tcp"}
+		}), map[model.ConfigKey][]int{key: {8080, 9090}}},
+		{"port removed", modify(func(se *networking.ServiceEntry) {
+			se.Ports = se.Ports[:1]
+		}), map[model.ConfigKey][]int{key: {8080}}},
+		{"location changed", modify(func(se *networking.ServiceEntry) {
+			se.Ports = se.Ports[:1]
+			se.Location = networking.ServiceEntry_MESH_INTERNAL
+		}), nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			createConfigs([]*config.Config{tt.cfg}, store, t)
+			req := pushed()
+			if _, f := req.ConfigsUpdated[key]; !f {
+				t.Fatalf("expected %v to be updated, got %v", key, req.ConfigsUpdated)
+			}
+			if !reflect.DeepEqual(req.ServicePorts, tt.want) {
+				t.Fatalf("ServicePorts = %v, want %v", req.ServicePorts, tt.want)
+			}
+		})
+	}
+}
+
 func TestServiceDiscoveryWorkloadInstance(t *testing.T) {
 	store, sd, events, stopFn := initServiceDiscovery()
 	defer stopFn()
@@ -864,6 +978,75 @@ func TestServiceDiscoveryWorkloadInstance(t *testing.T) {
 	})
 }
 
+func TestServiceDiscoveryWorkloadSelectorChange(t *testing.T) {
+	store, sd, events, stopFn := initServiceDiscovery()
+	defer stopFn()
+
+	narrowSelector := func() *config.Config {
+		c := selector.DeepCopy()
+		se := c.Spec.(*networking.ServiceEntry)
+		se.WorkloadSelector = &networking.WorkloadSelector{
+			Labels: map[string]string{"app": "wle", "version": "v1"},
+		}
+		return &c
+	}()
+
+	fi1 := &model.WorkloadInstance{
+		Name:      selector.Name,
+		Namespace: selector.Namespace,
+		Endpoint: &model.IstioEndpoint{
+			Address:        "2.2.2.2",
+			Labels:         map[string]string{"app": "wle", "version": "v1"},
+			ServiceAccount: spiffe.MustGenSpiffeURI(selector.Name, "default"),
+			TLSMode:        model.IstioMutualTLSModeLabel,
+		},
+	}
+	fi2 := &model.WorkloadInstance{
+		Name:      "some-other-name",
+		Namespace: selector.Namespace,
+		Endpoint: &model.IstioEndpoint{
+			Address:        "3.3.3.3",
+			Labels:         map[string]string{"app": "wle"},
+			ServiceAccount: spiffe.MustGenSpiffeURI(selector.Name, "default"),
+			TLSMode:        model.IstioMutualTLSModeLabel,
+			// A pod in a Kubernetes cluster, whose proxy must be pushed in its own cluster.
+			Locality: model.Locality{ClusterID: "Kubernetes"},
+		},
+	}
+
+	createConfigs([]*config.Config{narrowSelector}, store, t)
+	expectEvents(t, events,
+		Event{kind: "svcupdate", host: "selector.com", namespace: selector.Namespace},
+		Event{kind: "xds"})
+	callInstanceHandlers([]*model.WorkloadInstance{fi1, fi2}, sd, model.EventAdd, t)
+	expectEvents(t, events, Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 2})
+	expectProxyInstances(t, sd, []*model.ServiceInstance{}, "3.3.3.3")
+
+	// Widen the selector so that it also selects fi2. This should result in an EDS push for the service,
+	// and a push to fi2, which is now an instance of the service.
+	createConfigs([]*config.Config{selector}, store, t)
+	expectEvents(t, events,
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 4},
+		Event{kind: "xds", proxyIP: "3.3.3.3", proxyCluster: "Kubernetes"})
+	instances := []*model.ServiceInstance{
+		makeInstanceWithServiceAccount(selector, "3.3.3.3", 444,
+			selector.Spec.(*networking.ServiceEntry).Ports[0], map[string]string{"app": "wle"}, "default"),
+		makeInstanceWithServiceAccount(selector, "3.3.3.3", 445,
+			selector.Spec.(*networking.ServiceEntry).Ports[1], map[string]string{"app": "wle"}, "default"),
+	}
+	for _, instance := range instances {
+		instance.Endpoint.Locality.ClusterID = "Kubernetes"
+	}
+	expectProxyInstances(t, sd, instances, "3.3.3.3")
+
+	// Narrowing the selector again pushes fi2 in its cluster, as it is no longer an instance of the service.
+	createConfigs([]*config.Config{narrowSelector}, store, t)
+	expectEvents(t, events,
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 2},
+		Event{kind: "xds", proxyIP: "3.3.3.3", proxyCluster: "Kubernetes"})
+	expectProxyInstances(t, sd, []*model.ServiceInstance{}, "3.3.3.3")
+}
+
 func expectProxyInstances(t *testing.T, sd *ServiceEntryStore, expected []*model.ServiceInstance, ip string) {
 	t.Helper()
 	// The system is eventually consistent, so add some retries
@@ -1118,6 +1301,16 @@ func TestServicesDiff(t *testing.T) {
 			}(),
 			updated: stringsToHosts(updatedHTTPDNS.Spec.(*networking.ServiceEntry).Hosts),
 		},
+		{
+			name: "different location",
+			a:    updatedHTTPDNS,
+			b: func() *config.Config {
+				c := updatedHTTPDNS.DeepCopy()
+				c.Spec.(*networking.ServiceEntry).Location = networking.ServiceEntry_MESH_INTERNAL
+				return &c
+			}(),
+			updated: stringsToHosts(updatedHTTPDNS.Spec.(*networking.ServiceEntry).Hosts),
+		},
 		{
 			name: "config modified with added/deleted host",
 			a:    updatedHTTPDNS,
@@ -101,6 +101,10 @@ type Connection struct {
 	// (last push not ACKed). When we get an ACK from Envoy, if the type is populated here, we will trigger
 	// the push.
 	blockedPushes map[string]*model.PushRequest
+
+	// skippedPushes are the most recent pushes skipped before the proxy had a SidecarScope. They are
+	// re-evaluated once the scope is computed, as they may apply to the proxy after all.
+	skippedPushes skippedPushes
 }
 
 // Event represents a config or registry event that results in a push.
@@ -617,6 +621,7 @@ func (s *DiscoveryServer) updateProxy(proxy *model.Proxy, push *model.PushContex
 
 func (s *DiscoveryServer) setProxyState(proxy *model.Proxy, push *model.PushContext) {
 	proxy.SetWorkloadLabels(s.Env)
+	proxy.SetNamespaceLabels(s.Env)
 	proxy.SetServiceInstances(push.ServiceDiscovery)
 
 	// Precompute the sidecar scope and merged gateways associated with this proxy.
@@ -662,19 +667,30 @@ func (s *DiscoveryServer) DeltaAggregatedResources(stream discovery.AggregatedDi
 func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 	pushRequest := pushEv.pushRequest
 
+	hadScope := con.proxy.SidecarScope != nil
 	if pushRequest.Full {
 		// Update Proxy with current information.
 		s.updateProxy(con.proxy, pushRequest.Push)
 	}
+	if !hadScope && con.proxy.SidecarScope != nil {
+		if catchUp := con.skippedPushes.catchUp(con.proxy, s.ProxyNeedsPush); catchUp != nil {
+			adsLog.Debugf("Catching up on pushes skipped before the scope of %v was computed", con.ConID)
+			pushRequest = catchUp.Merge(pushRequest)
+		}
+	}
 
 	if !s.ProxyNeedsPush(con.proxy, pushRequest) {
-		adsLog.Debugf("Skipping push to %v, no updates required", con.ConID)
+		adsLog.Debugf("Skipping push to %v, no updates required (correlation id %q)", con.ConID, pushRequest.CorrelationID)
+		if con.proxy.SidecarScope == nil {
+			con.skippedPushes.add(pushRequest)
+		}
 		if pushRequest.Full {
 			// Only report for full versions, incremental pushes do not have a new version
 			reportAllEvents(s.StatusReporter, con.ConID, pushRequest.Push.LedgerVersion, nil)
 		}
 		return nil
 	}
+	s.pushAccounting.record(con.proxy.ID, time.Now())
 
 	currentVersion := versionInfo()
 
@@ -710,7 +726,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 			totalDelayedPushes.With(typeTag.Value(v3.GetMetricType(w.TypeUrl))).Increment()
 			adsLog.Debugf("%s: QUEUE for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
 			con.proxy.Lock()
-			con.blockedPushes[w.TypeUrl] = con.blockedPushes[w.TypeUrl].Merge(pushEv.pushRequest)
+			con.blockedPushes[w.TypeUrl] = con.blockedPushes[w.TypeUrl].Merge(pushRequest)
 			con.proxy.Unlock()
 		}
 	}
@@ -777,7 +793,7 @@ func (s *DiscoveryServer) ProxyUpdate(clusterID, ip string) {
 	var connection *Connection
 
 	for _, v := range s.Clients() {
-		if v.proxy.Metadata.ClusterID == clusterID && v.proxy.IPAddresses[0] == ip {
+		if v.proxy.Metadata.ClusterID == clusterID && hasIPAddress(v.proxy, ip) {
 			connection = v
 			break
 		}
@@ -802,6 +818,17 @@ func (s *DiscoveryServer) ProxyUpdate(clusterID, ip string) {
 	})
 }
 
+// hasIPAddress returns whether the ip is one of the proxy's addresses. Dual-stack proxies have an address
+// per family, in no particular order.
+func hasIPAddress(proxy *model.Proxy, ip string) bool {
+	for _, addr := range proxy.IPAddresses {
+		if addr == ip {
+			return true
+		}
+	}
+	return false
+}
+
 // AdsPushAll will send updates to all nodes, for a full config or incremental EDS.
 func AdsPushAll(s *DiscoveryServer) {
 	s.AdsPushAll(versionInfo(), &model.PushRequest{
@@ -851,7 +878,17 @@ func (s *DiscoveryServer) startPush(req *model.PushRequest) {
 		}
 	}
 	req.Start = time.Now()
-	for _, p := range s.AllClients() {
+	clients := s.AllClients()
+	if features.EnableZeroTargetPushMetric {
+		proxies := make([]*model.Proxy, 0, len(clients))
+		for _, con := range clients {
+			if con.proxy != nil {
+				proxies = append(proxies, con.proxy)
+			}
+		}
+		PartitionProxiesForPush(req, proxies, s.ProxyNeedsPush)
+	}
+	for _, p := range clients {
 		s.pushQueue.Enqueue(p, req)
 	}
 }
@@ -872,6 +909,7 @@ func (s *DiscoveryServer) removeCon(conID string) {
 	} else {
 		delete(s.adsClients, conID)
 		recordXDSClients(con.proxy.Metadata.IstioVersion, -1)
+		s.pushAccounting.remove(con.proxy.ID)
 	}
 
 	if s.StatusReporter != nil {
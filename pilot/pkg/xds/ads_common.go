@@ -15,8 +15,23 @@
 package xds
 
 import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -25,6 +40,54 @@ var configKindAffectedProxyTypes = map[config.GroupVersionKind][]model.NodeType{
 	gvk.Gateway: {model.Router},
 	gvk.Secret:  {model.Router},
 	gvk.Sidecar: {model.SidecarProxy},
+	// WorkloadGroups only affect proxies through the WorkloadEntries created from them.
+	gvk.WorkloadGroup: {},
+}
+
+// selectorScopedKinds are the kinds whose changes only affect the sidecars of the workloads selected by the
+// previous or current version of the changed config. See RegisterSelectorScope.
+var selectorScopedKinds = map[config.GroupVersionKind]struct{}{
+	gvk.PeerAuthentication:    {},
+	gvk.RequestAuthentication: {},
+}
+
+// RegisterSelectorScope scopes the changes of the kinds to the sidecars of the workloads selected by the changed
+// configs, as is done for the security kinds by default. Only the kinds the push context keeps the previous
+// versions of can be scoped, see PushContext.AuthenticationPolicyAffectsWorkload; changes of other kinds still
+// affect all sidecars. It must be called before the discovery server is started.
+func RegisterSelectorScope(kinds []config.GroupVersionKind) {
+	for _, kind := range kinds {
+		selectorScopedKinds[kind] = struct{}{}
+	}
+}
+
+// retiredConfigKinds are kinds that were removed from the schema. Resources of these kinds may linger after
+// an upgrade, and still be seen as updated, but are no longer used to build any configuration, so their
+// changes must not be treated as unknown kinds, which push everything.
+var retiredConfigKinds = map[config.GroupVersionKind]struct{}{
+	{Group: "rbac.istio.io", Version: "v1alpha1", Kind: "ServiceRole"}:          {},
+	{Group: "rbac.istio.io", Version: "v1alpha1", Kind: "ServiceRoleBinding"}:   {},
+	{Group: "rbac.istio.io", Version: "v1alpha1", Kind: "RbacConfig"}:           {},
+	{Group: "rbac.istio.io", Version: "v1alpha1", Kind: "ClusterRbacConfig"}:    {},
+	{Group: "authentication.istio.io", Version: "v1alpha1", Kind: "Policy"}:     {},
+	{Group: "authentication.istio.io", Version: "v1alpha1", Kind: "MeshPolicy"}: {},
+}
+
+// kindAffectsProxyType returns whether changes of the kind can affect proxies of the type.
+func kindAffectsProxyType(kind config.GroupVersionKind, proxyType model.NodeType) bool {
+	if _, f := retiredConfigKinds[kind]; f {
+		return false
+	}
+	kindAffectedTypes, f := configKindAffectedProxyTypes[kind]
+	if !f {
+		return true
+	}
+	for _, t := range kindAffectedTypes {
+		if t == proxyType {
+			return true
+		}
+	}
+	return false
 }
 
 // ConfigAffectsProxy checks if a pushEv will affect a specified proxy. That means whether the push will be performed
@@ -35,32 +98,372 @@ func ConfigAffectsProxy(req *model.PushRequest, proxy *model.Proxy) bool {
 		return true
 	}
 
+	// Configs without a kind or of retired kinds are checked first, so that they are recorded whichever order
+	// the configs are iterated in, rather than only when no other config affecting the proxy is seen before them.
+	for config := range req.ConfigsUpdated {
+		if _, f := retiredConfigKinds[config.Kind]; f {
+			recordRetiredConfigKind(config)
+		}
+		if isZeroConfigKind(config.Kind) {
+			// Nothing is known about what the config affects, so push everything, but let
+			// operators know the producer of the request is broken.
+			recordInvalidConfigKind(config, req.CorrelationID)
+			return true
+		}
+	}
+
+	for config := range req.ConfigsUpdated {
+		if configSkipReason(req, proxy, config) == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PushSkipReason describes why a push request was not sent to a proxy.
+type PushSkipReason string
+
+const (
+	// SkipReasonCluster is used when the push request is limited to proxies of other clusters.
+	SkipReasonCluster PushSkipReason = "cluster not targeted"
+	// SkipReasonNamespaceSelector is used when the push request is limited to proxies of namespaces with
+	// other labels.
+	SkipReasonNamespaceSelector PushSkipReason = "namespace not selected"
+	// SkipReasonProxyType is used when the updated kinds do not affect proxies of the type, such as
+	// Gateways for sidecars.
+	SkipReasonProxyType PushSkipReason = "config kind does not affect proxy type"
+	// SkipReasonRetiredKind is used when the updated configs are of kinds removed from the schema.
+	SkipReasonRetiredKind PushSkipReason = "config kind retired"
+	// SkipReasonEnvoyFilterTarget is used when the updated EnvoyFilters only target other proxy types.
+	SkipReasonEnvoyFilterTarget PushSkipReason = "envoy filters target other proxy types"
+	// SkipReasonNotExported is used when the updated DestinationRules are not exported to the proxy's namespace.
+	SkipReasonNotExported PushSkipReason = "destination rules not exported to namespace"
+	// SkipReasonPortNotImported is used when the proxy imports none of the updated service ports.
+	SkipReasonPortNotImported PushSkipReason = "service ports not imported"
+	// SkipReasonNotSelected is used when the updated configs of selector scoped kinds, such as
+	// PeerAuthentications, do not select the proxy's workload.
+	SkipReasonNotSelected PushSkipReason = "workload not selected"
+	// SkipReasonNoDependency is used when the proxy's scope depends on none of the updated configs, as they
+	// are in other namespaces or are services it does not import.
+	SkipReasonNoDependency PushSkipReason = "no dependency on configs"
+	// SkipReasonNoEndpointChange is used when the push request is an incremental update of services, which
+	// only pushes endpoints, but changes none, such as when all of its services are draining.
+	SkipReasonNoEndpointChange PushSkipReason = "no endpoint change"
+	// SkipReasonMixed is used when the updated configs were skipped for different reasons.
+	SkipReasonMixed PushSkipReason = "mixed"
+)
+
+// configSkipReason returns why the updated config does not affect the proxy, or an empty reason if it does.
+func configSkipReason(req *model.PushRequest, proxy *model.Proxy, config model.ConfigKey) PushSkipReason {
+	if _, f := retiredConfigKinds[config.Kind]; f {
+		return SkipReasonRetiredKind
+	}
+
+	// Some configKinds only affect specific proxy types
+	if !kindAffectsProxyType(config.Kind, proxy.Type) {
+		return SkipReasonProxyType
+	}
+
+	if config.Kind == gvk.EnvoyFilter && !envoyFilterTargetsProxy(req.EnvoyFilterTarget, proxy) {
+		return SkipReasonEnvoyFilterTarget
+	}
+
+	if config.Kind == gvk.DestinationRule && !destinationRuleVisibleToProxy(req.DestinationRuleNamespaces, proxy) {
+		return SkipReasonNotExported
+	}
+
+	if config.Kind == gvk.ServiceEntry && proxy.Type == model.SidecarProxy {
+		if ports, f := req.ServicePorts[config]; f && !importsServicePort(proxy, host.Name(config.Name), ports) {
+			return SkipReasonPortNotImported
+		}
+	}
+
+	if !checkProxyDependencies(proxy, config, req.Push) && !importsDestinationRuleHost(proxy, req.DestinationRuleHosts[config]) {
+		if _, f := selectorScopedKinds[config.Kind]; f {
+			return SkipReasonNotSelected
+		}
+		return SkipReasonNoDependency
+	}
+	return ""
+}
+
+// PushSkipReport returns, keyed by proxy ID, why each of the proxies DefaultProxyNeedsPush skips for the push
+// request was skipped. Proxies the request is pushed to are not included. This is intended to debug why a
+// proxy did not receive an update.
+func PushSkipReport(proxies []*model.Proxy, req *model.PushRequest) map[string]PushSkipReason {
+	req = applyClassificationProfile(req)
+	report := map[string]PushSkipReason{}
+	for _, proxy := range proxies {
+		if DefaultProxyNeedsPush(proxy, req) {
+			continue
+		}
+		if !clusterIDsIncludeProxy(req.ClusterIDs, proxy) {
+			report[proxy.ID] = SkipReasonCluster
+			continue
+		}
+		if !namespaceSelectorsIncludeProxy(req.NamespaceSelectors, proxy) {
+			report[proxy.ID] = SkipReasonNamespaceSelector
+			continue
+		}
+		if emptyIncrementalPush(req) {
+			report[proxy.ID] = SkipReasonNoEndpointChange
+			continue
+		}
+		var reason PushSkipReason
+		for config := range req.ConfigsUpdated {
+			if r := configSkipReason(req, proxy, config); reason == "" {
+				reason = r
+			} else if r != reason {
+				reason = SkipReasonMixed
+				break
+			}
+		}
+		report[proxy.ID] = reason
+	}
+	return report
+}
+
+// DecisionCode is a compact summary of the push decision for a proxy, for dumps of the decisions for many
+// proxies where a PushSkipReason for each would be too large.
+type DecisionCode byte
+
+const (
+	// DecisionSkipped is used when the push request is not sent to the proxy.
+	DecisionSkipped DecisionCode = iota
+	// DecisionFullPush is used when the push request does not tell which configs changed, so it is sent to
+	// all proxies.
+	DecisionFullPush
+	// DecisionNamespaceMatch is used when the sidecar is pushed for configs its scope does not track as
+	// dependencies, which are only matched by their namespace, if at all.
+	DecisionNamespaceMatch
+	// DecisionServiceDep is used when the sidecar is pushed for configs its scope depends on, or for one of
+	// its own services.
+	DecisionServiceDep
+	// DecisionGatewayMatch is used when the gateway is pushed.
+	DecisionGatewayMatch
+)
+
+func (c DecisionCode) String() string {
+	switch c {
+	case DecisionSkipped:
+		return "skipped"
+	case DecisionFullPush:
+		return "full push"
+	case DecisionNamespaceMatch:
+		return "namespace match"
+	case DecisionServiceDep:
+		return "service dependency"
+	case DecisionGatewayMatch:
+		return "gateway match"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(c))
+	}
+}
+
+// scopeDependencyKinds are the kinds a SidecarScope matches against its dependencies.
+var scopeDependencyKinds = func() map[config.GroupVersionKind]struct{} {
+	kinds := map[config.GroupVersionKind]struct{}{}
+	for _, kind := range model.RegisteredPushScopeKinds() {
+		kinds[kind] = struct{}{}
+	}
+	return kinds
+}()
+
+// PushDecisionCode returns whether DefaultProxyNeedsPush pushes the request to the proxy, and why, as a
+// DecisionCode. Use PushSkipReport for the details of skipped proxies.
+func PushDecisionCode(proxy *model.Proxy, req *model.PushRequest) DecisionCode {
+	req = applyClassificationProfile(req)
+	if !DefaultProxyNeedsPush(proxy, req) {
+		return DecisionSkipped
+	}
+	if len(req.ConfigsUpdated) == 0 {
+		return DecisionFullPush
+	}
+	for config := range req.ConfigsUpdated {
+		if isZeroConfigKind(config.Kind) {
+			return DecisionFullPush
+		}
+	}
+	if proxy.Type == model.Router {
+		return DecisionGatewayMatch
+	}
 	for config := range req.ConfigsUpdated {
-		affected := true
-
-		// Some configKinds only affect specific proxy types
-		if kindAffectedTypes, f := configKindAffectedProxyTypes[config.Kind]; f {
-			affected = false
-			for _, t := range kindAffectedTypes {
-				if t == proxy.Type {
-					affected = true
-					break
-				}
+		if _, f := scopeDependencyKinds[config.Kind]; f && configSkipReason(req, proxy, config) == "" {
+			return DecisionServiceDep
+		}
+	}
+	if proxyServicesUpdated(proxy, req) {
+		return DecisionServiceDep
+	}
+	return DecisionNamespaceMatch
+}
+
+// PushDecisionRecord is the push decision for a proxy, in a form that can be serialized by debug endpoints.
+type PushDecisionRecord struct {
+	ProxyID string `json:"proxyId"`
+	// Fingerprint identifies what the push request asks to be pushed. See PushRequest.Fingerprint.
+	Fingerprint string `json:"fingerprint"`
+	NeedsPush   bool   `json:"needsPush"`
+	// Types are the sorted type URLs pushed to the proxy, if it needs a push.
+	Types []string `json:"types,omitempty"`
+	// Decision is the DecisionCode of the push decision.
+	Decision string `json:"decision"`
+	// SkipReason is why the proxy was skipped, if it does not need a push.
+	SkipReason PushSkipReason `json:"skipReason,omitempty"`
+}
+
+// BuildPushDecisionRecords returns the push decision for each of the proxies for the push request, in the
+// order of the proxies. It does not modify the proxies or the request.
+func BuildPushDecisionRecords(proxies []*model.Proxy, req *model.PushRequest) []PushDecisionRecord {
+	req = applyClassificationProfile(req)
+	fingerprint := req.Fingerprint()
+	skipped := PushSkipReport(proxies, req)
+	records := make([]PushDecisionRecord, 0, len(proxies))
+	for _, proxy := range proxies {
+		record := PushDecisionRecord{
+			ProxyID:     proxy.ID,
+			Fingerprint: fingerprint,
+			Decision:    PushDecisionCode(proxy, req).String(),
+		}
+		if needsPush, types := EvaluatePush(proxy, req); needsPush {
+			record.NeedsPush = true
+			for typeURL := range types {
+				record.Types = append(record.Types, typeURL)
 			}
+			sort.Strings(record.Types)
+		} else {
+			record.SkipReason = skipped[proxy.ID]
 		}
+		records = append(records, record)
+	}
+	return records
+}
 
-		if affected && checkProxyDependencies(proxy, config) {
+// invalidConfigKindWarnLimit limits how often updated configs without a kind are logged, as they are seen
+// once for every proxy considered for the push.
+var invalidConfigKindWarnLimit = rate.NewLimiter(rate.Every(time.Minute), 1)
+
+// logInvalidConfigKind logs an updated config without a kind. It is a variable so tests can observe it.
+var logInvalidConfigKind = func(key model.ConfigKey, correlationID string) {
+	adsLog.Warnf("push request updates config %s/%s without a kind, it is likely a bug in its producer (correlation id %q)",
+		key.Namespace, key.Name, correlationID)
+}
+
+func isZeroConfigKind(kind config.GroupVersionKind) bool {
+	return kind == config.GroupVersionKind{}
+}
+
+// recordInvalidConfigKind counts an updated config without a kind, and logs it at most once a minute.
+func recordInvalidConfigKind(key model.ConfigKey, correlationID string) {
+	invalidConfigKindUpdates.Increment()
+	if invalidConfigKindWarnLimit.Allow() {
+		logInvalidConfigKind(key, correlationID)
+	}
+}
+
+// retiredConfigKindWarnLimit limits how often updated configs of retired kinds are logged, as they are seen
+// once for every proxy considered for the push.
+var retiredConfigKindWarnLimit = rate.NewLimiter(rate.Every(time.Minute), 1)
+
+// logRetiredConfigKind logs an updated config of a retired kind. It is a variable so tests can observe it.
+var logRetiredConfigKind = func(key model.ConfigKey) {
+	adsLog.Warnf("config %s/%s of the deprecated kind %v was updated, it is no longer used and should be removed",
+		key.Namespace, key.Name, key.Kind)
+}
+
+// recordRetiredConfigKind counts an updated config of a retired kind, and logs it at most once a minute.
+func recordRetiredConfigKind(key model.ConfigKey) {
+	retiredConfigKindUpdates.With(typeTag.Value(key.Kind.Kind)).Increment()
+	if retiredConfigKindWarnLimit.Allow() {
+		logRetiredConfigKind(key)
+	}
+}
+
+// envoyFilterTargetsProxy returns whether EnvoyFilter changes with the target can affect the proxy.
+func envoyFilterTargetsProxy(target model.EnvoyFilterTarget, proxy *model.Proxy) bool {
+	switch target {
+	case model.EnvoyFilterTargetSidecars:
+		return proxy.Type == model.SidecarProxy
+	case model.EnvoyFilterTargetGateways:
+		return proxy.Type == model.Router
+	default:
+		return true
+	}
+}
+
+// destinationRuleVisibleToProxy returns whether DestinationRule changes exported to the namespaces can affect
+// the proxy. Destination rules are only looked up from the config namespace of the proxy, so proxies in other
+// namespaces cannot see them.
+func destinationRuleVisibleToProxy(namespaces map[string]struct{}, proxy *model.Proxy) bool {
+	if namespaces == nil {
+		return true
+	}
+	_, f := namespaces[proxy.ConfigNamespace]
+	return f
+}
+
+// importsServicePort returns whether the current or previous scope of the sidecar imports any of the ports of
+// the service. Wildcard hosts may match many services, so they are left to the scope's dependencies.
+func importsServicePort(proxy *model.Proxy, hostname host.Name, ports []int) bool {
+	if hostname.IsWildCarded() {
+		return true
+	}
+	for _, port := range ports {
+		if proxy.SidecarScope.DependsOnServicePort(hostname, port) {
+			return true
+		}
+		if proxy.PrevSidecarScope != nil && proxy.PrevSidecarScope.DependsOnServicePort(hostname, port) {
 			return true
 		}
 	}
+	return false
+}
 
+// importsDestinationRuleHost returns whether the current or previous scope of the sidecar imports any of the
+// hosts of a changed destination rule. The scope only depends on the rules it selected by name, so this also
+// catches rules that were renamed or newly match one of its services.
+func importsDestinationRuleHost(proxy *model.Proxy, hosts []host.Name) bool {
+	if proxy.Type != model.SidecarProxy {
+		return false
+	}
+	for _, hostname := range hosts {
+		if proxy.SidecarScope.DependsOnHost(hostname) {
+			return true
+		}
+		if proxy.PrevSidecarScope != nil && proxy.PrevSidecarScope.DependsOnHost(hostname) {
+			return true
+		}
+	}
 	return false
 }
 
-func checkProxyDependencies(proxy *model.Proxy, config model.ConfigKey) bool {
+func checkProxyDependencies(proxy *model.Proxy, config model.ConfigKey, push *model.PushContext) bool {
 	// Detailed config dependencies check.
 	switch proxy.Type {
 	case model.SidecarProxy:
+		// Policies with a selector, such as peer authentications with port-level mTLS settings, only affect
+		// the workloads they select, and only their inbound configuration.
+		if _, f := selectorScopedKinds[config.Kind]; f && push != nil && proxy.Metadata != nil &&
+			activeClassificationProfile() == ClassificationAggressive {
+			return selectorAffectsProxy(push, config, proxy)
+		}
+		// Sidecar changes recompute the scope itself, so they are matched by the scope's namespace and root
+		// namespace only, never by its dependencies. Every proxy in the namespace of a changed Sidecar gets a
+		// full push, regardless of what the recomputed scope depends on.
+		// Virtual services are only dependencies of the scope if they are bound to the mesh gateway, so
+		// changes to virtual services bound only to gateways never push sidecars.
+		if proxy.SidecarScope.DependsOnConfig(config) {
+			return true
+		} else if proxy.PrevSidecarScope != nil && proxy.PrevSidecarScope.DependsOnConfig(config) {
+			return true
+		}
+	case model.Router:
+		// Virtual services only affect a gateway if they are bound to one of the gateways it serves and
+		// are visible from its namespace. These are recorded as dependencies of the gateway's scope.
+		if config.Kind != gvk.VirtualService {
+			return true
+		}
 		if proxy.SidecarScope.DependsOnConfig(config) {
 			return true
 		} else if proxy.PrevSidecarScope != nil && proxy.PrevSidecarScope.DependsOnConfig(config) {
@@ -73,23 +476,745 @@ func checkProxyDependencies(proxy *model.Proxy, config model.ConfigKey) bool {
 	return false
 }
 
+// selectorAffectsProxy returns whether the previous or current version of the changed config of a selector scoped
+// kind may affect the sidecar. Sidecars without inbound listeners are only affected by configs without a selector.
+func selectorAffectsProxy(push *model.PushContext, config model.ConfigKey, proxy *model.Proxy) bool {
+	if !hasInboundListeners(proxy) {
+		return push.AuthenticationPolicyAffectsClients(config)
+	}
+	return push.AuthenticationPolicyAffectsWorkload(config, proxy.Metadata.Namespace, labels.Collection{proxy.Metadata.Labels})
+}
+
+// hasInboundListeners returns whether the sidecar may receive inbound traffic. Without traffic interception,
+// listeners are only built for the ingress listeners of the Sidecar resource, so a sidecar without them is
+// outbound only. Its catch all inbound listener is not reachable, as no traffic is redirected to it.
+func hasInboundListeners(proxy *model.Proxy) bool {
+	if proxy.GetInterceptionMode() != model.InterceptionNone {
+		return true
+	}
+	return proxy.SidecarScope == nil || proxy.SidecarScope.HasCustomIngressListeners
+}
+
+// clusterIDsIncludeProxy returns whether the proxy is in one of the clusters a push request is limited to.
+// Proxies without a cluster ID can not be told apart, so they are always included.
+func clusterIDsIncludeProxy(clusterIDs map[string]struct{}, proxy *model.Proxy) bool {
+	clusterID := proxy.GetClusterID()
+	if clusterIDs == nil || clusterID == "" {
+		return true
+	}
+	_, f := clusterIDs[clusterID]
+	return f
+}
+
+// namespaceSelectorsIncludeProxy returns whether the namespace of the proxy matches one of the selectors a
+// push request is limited to. Proxies whose namespace labels are unknown are always included.
+func namespaceSelectorsIncludeProxy(selectors labels.Collection, proxy *model.Proxy) bool {
+	namespaceLabels := proxy.GetNamespaceLabels()
+	if len(selectors) == 0 || namespaceLabels == nil {
+		return true
+	}
+	return selectors.HasSubsetOf(namespaceLabels)
+}
+
+// ClassificationProfile selects how far pushes are scoped by the optional hints push requests carry and by
+// the selectors of the updated policies.
+type ClassificationProfile int
+
+const (
+	// ClassificationAggressive uses all hints and selectors to push as little as possible. This is the default.
+	ClassificationAggressive ClassificationProfile = iota
+	// ClassificationConservative ignores them, pushing every type each updated kind may affect to every proxy
+	// depending on it. This pushes more, but does not rely on the producers of the hints being correct.
+	ClassificationConservative
+)
+
+// classificationProfile is the profile in use. It is only expected to be changed on startup.
+var classificationProfile = atomic.NewInt32(int32(ClassificationAggressive))
+
+// SetClassificationProfile sets the classification profile used for all pushes. It must be called before the
+// server starts pushing.
+func SetClassificationProfile(profile ClassificationProfile) {
+	classificationProfile.Store(int32(profile))
+}
+
+// activeClassificationProfile returns the classification profile in use.
+func activeClassificationProfile() ClassificationProfile {
+	return ClassificationProfile(classificationProfile.Load())
+}
+
+// ClassificationRulesVersion names a version of the rules the generators use to skip xDS types for config
+// changes. Optimizations are added in new versions, so that operators can switch back to the previous rules at
+// runtime if they misbehave.
+type ClassificationRulesVersion string
+
+const (
+	// ClassificationRulesV1 are the rules the generators have always used. This is the default.
+	ClassificationRulesV1 ClassificationRulesVersion = "v1"
+	// ClassificationRulesV2 are the v1 rules, except that VirtualService changes limited to route settings also
+	// skip CDS, as they do not change the destinations clusters are built for.
+	ClassificationRulesV2 ClassificationRulesVersion = "v2"
+)
+
+// classificationRules are the tables the generators consult to skip xDS types for changes of config kinds.
+type classificationRules struct {
+	// skippedConfigs are, keyed by type URL, the kinds whose changes do not affect the type.
+	skippedConfigs map[string]map[config.GroupVersionKind]struct{}
+	// routeSettingsSkipClusters is set if VirtualService changes limited to route settings skip CDS.
+	routeSettingsSkipClusters bool
+}
+
+// skipped returns whether changes of the kind do not affect the xDS type.
+func (r *classificationRules) skipped(typeURL string, kind config.GroupVersionKind) bool {
+	_, f := r.skippedConfigs[typeURL][kind]
+	return f
+}
+
+// defaultSkippedConfigs are, keyed by type URL, the kinds each generator has always skipped.
+var defaultSkippedConfigs = map[string]map[config.GroupVersionKind]struct{}{
+	v3.ClusterType:  skippedCdsConfigs,
+	v3.EndpointType: skippedEdsConfigs,
+	v3.ListenerType: skippedLdsConfigs,
+	v3.RouteType:    skippedRdsConfigs,
+}
+
+// classificationRuleVersions are the versions of the classification rules that can be selected.
+var classificationRuleVersions = map[ClassificationRulesVersion]*classificationRules{
+	ClassificationRulesV1: {
+		skippedConfigs: defaultSkippedConfigs,
+	},
+	ClassificationRulesV2: {
+		skippedConfigs:            defaultSkippedConfigs,
+		routeSettingsSkipClusters: true,
+	},
+}
+
+// classificationRulesVersion is the version of the classification rules in use.
+var classificationRulesVersion = atomic.NewString(string(ClassificationRulesV1))
+
+// SetClassificationRules sets the version of the classification rules used for all pushes. Unlike the
+// classification profile, it may be changed while the server is pushing, and applies from the next push.
+func SetClassificationRules(version ClassificationRulesVersion) error {
+	if _, f := classificationRuleVersions[version]; !f {
+		return fmt.Errorf("unknown classification rules version %q", version)
+	}
+	classificationRulesVersion.Store(string(version))
+	return nil
+}
+
+// activeClassificationRules returns the classification rules in use.
+func activeClassificationRules() *classificationRules {
+	return classificationRuleVersions[ClassificationRulesVersion(classificationRulesVersion.Load())]
+}
+
+// pushEverything returns a copy of the push request that pushes everything to all proxies.
+func pushEverything(req *model.PushRequest) *model.PushRequest {
+	full := clearPushHints(req)
+	full.Full = true
+	full.ConfigsUpdated = nil
+	full.ConfigGenerations = nil
+	return full
+}
+
+// applyClassificationProfile returns the push request with the hints the classification profile ignores
+// cleared. The request itself is not modified.
+func applyClassificationProfile(req *model.PushRequest) *model.PushRequest {
+	if req == nil {
+		return req
+	}
+	if activeClassificationProfile() != ClassificationConservative {
+		return req
+	}
+	return clearPushHints(req)
+}
+
+// clearPushHints returns a copy of the push request without any of the optional hints scoping it. Hints that
+// only widen a push, DestinationRuleHosts, and ConfigGenerations, which are only used to drop stale requests
+// when they are received, are kept.
+func clearPushHints(req *model.PushRequest) *model.PushRequest {
+	cleared := *req
+	cleared.GatewayChange = model.GatewayChangeStructural
+	cleared.EnvoyFilterTarget = model.EnvoyFilterTargetAll
+	cleared.DestinationRuleNamespaces = nil
+	cleared.DestinationRuleChange = model.DestinationRuleChangeStructural
+	cleared.VirtualServiceChange = model.VirtualServiceChangeStructural
+	cleared.ClusterIDs = nil
+	cleared.NamespaceSelectors = nil
+	cleared.ServicePorts = nil
+	cleared.EndpointDeltas = nil
+	cleared.DrainingServices = nil
+	cleared.StatsOnly = false
+	return &cleared
+}
+
 // DefaultProxyNeedsPush check if a proxy needs push for this push event.
 func DefaultProxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
+	req = applyClassificationProfile(req)
+	if !clusterIDsIncludeProxy(req.ClusterIDs, proxy) {
+		logSkippedPush(proxy, req, SkipReasonCluster)
+		return false
+	}
+
+	if !namespaceSelectorsIncludeProxy(req.NamespaceSelectors, proxy) {
+		logSkippedPush(proxy, req, SkipReasonNamespaceSelector)
+		return false
+	}
+
+	if emptyIncrementalPush(req) {
+		logSkippedPush(proxy, req, SkipReasonNoEndpointChange)
+		return false
+	}
+
 	if ConfigAffectsProxy(req, proxy) {
 		return true
 	}
 
 	// If the proxy's service updated, need push for it.
-	if len(proxy.ServiceInstances) > 0 && req.ConfigsUpdated != nil {
-		svc := proxy.ServiceInstances[0].Service
-		if _, ok := req.ConfigsUpdated[model.ConfigKey{
-			Kind:      gvk.ServiceEntry,
-			Name:      string(svc.Hostname),
-			Namespace: svc.Attributes.Namespace,
-		}]; ok {
-			return true
+	return proxyServicesUpdated(proxy, req)
+}
+
+// logSkippedPush logs that the push request is skipped for the proxy by the hints scoping it.
+func logSkippedPush(proxy *model.Proxy, req *model.PushRequest, reason PushSkipReason) {
+	adsLog.Debugf("Skipping push to %v, %s (correlation id %q)", proxy.ID, reason, req.CorrelationID)
+}
+
+// emptyIncrementalPush returns whether the push request is an incremental update of services that changes no
+// endpoints, such as when all of its services are draining. Incremental pushes only push endpoints, so there
+// is nothing to push.
+func emptyIncrementalPush(req *model.PushRequest) bool {
+	if req.Full || len(req.ConfigsUpdated) == 0 {
+		return false
+	}
+	for conf := range req.ConfigsUpdated {
+		// Incremental updates are only sent for services.
+		if conf.Kind != gvk.ServiceEntry {
+			return false
+		}
+	}
+	return !EventHasEndpointImpact(req)
+}
+
+// proxyServicesUpdated returns whether the push request updates any of the proxy's own services. The
+// instances of a dual-stack proxy are per address, so all of them are checked rather than assuming the
+// first address family.
+func proxyServicesUpdated(proxy *model.Proxy, req *model.PushRequest) bool {
+	if req.ConfigsUpdated != nil {
+		for _, si := range proxy.ServiceInstances {
+			svc := si.Service
+			if _, ok := req.ConfigsUpdated[model.ConfigKey{
+				Kind:      gvk.ServiceEntry,
+				Name:      string(svc.Hostname),
+				Namespace: svc.Attributes.Namespace,
+			}]; ok {
+				return true
+			}
 		}
 	}
 
 	return false
 }
+
+// pushTypeOverrides allows operators to override the xDS types pushed for a config kind. When a kind
+// has an override, the generators consult it instead of their own checks for that kind.
+var pushTypeOverrides = struct {
+	sync.RWMutex
+	types map[config.GroupVersionKind]map[string]bool
+}{types: map[config.GroupVersionKind]map[string]bool{}}
+
+// SetPushTypeOverride overrides the xDS types, keyed by type URL, pushed for changes of the kind.
+// Only CDS, EDS, LDS and RDS can be overridden, and as clusters reference endpoints, CDS implies EDS.
+func SetPushTypeOverride(kind config.GroupVersionKind, types map[string]bool) error {
+	for typeURL := range types {
+		switch typeURL {
+		case v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType:
+		default:
+			return fmt.Errorf("push type %s can not be overridden", typeURL)
+		}
+	}
+	if types[v3.ClusterType] && !types[v3.EndpointType] {
+		return fmt.Errorf("push type override for %s includes CDS but not EDS", kind)
+	}
+
+	copied := make(map[string]bool, len(types))
+	for typeURL, push := range types {
+		copied[typeURL] = push
+	}
+	pushTypeOverrides.Lock()
+	defer pushTypeOverrides.Unlock()
+	pushTypeOverrides.types[kind] = copied
+	return nil
+}
+
+// ClearPushTypeOverride removes the override of the xDS types pushed for changes of the kind.
+func ClearPushTypeOverride(kind config.GroupVersionKind) {
+	pushTypeOverrides.Lock()
+	defer pushTypeOverrides.Unlock()
+	delete(pushTypeOverrides.types, kind)
+}
+
+// pushTypeOverride returns whether the kind has an overridden set of xDS types, and if so, whether the
+// type is part of it.
+func pushTypeOverride(kind config.GroupVersionKind, typeURL string) (overridden bool, push bool) {
+	pushTypeOverrides.RLock()
+	defer pushTypeOverrides.RUnlock()
+	types, f := pushTypeOverrides.types[kind]
+	if !f {
+		return false, false
+	}
+	return true, types[typeURL]
+}
+
+// minPushTypes are the xDS types operators require to be pushed for a config kind, whatever the generators'
+// own checks and the hints of the push request decide. Unlike overrides, they only add types.
+var minPushTypes = struct {
+	sync.RWMutex
+	types map[config.GroupVersionKind]map[string]bool
+}{types: map[config.GroupVersionKind]map[string]bool{}}
+
+// SetMinPushTypes sets the xDS types, keyed by type URL, always pushed for full pushes of changes of the kind.
+// Only CDS, EDS, LDS and RDS can be required, and as clusters reference endpoints, CDS implies EDS.
+func SetMinPushTypes(kind config.GroupVersionKind, types map[string]bool) error {
+	for typeURL := range types {
+		switch typeURL {
+		case v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType:
+		default:
+			return fmt.Errorf("push type %s can not be required", typeURL)
+		}
+	}
+	if types[v3.ClusterType] && !types[v3.EndpointType] {
+		return fmt.Errorf("minimum push types for %s include CDS but not EDS", kind)
+	}
+
+	copied := make(map[string]bool, len(types))
+	for typeURL, push := range types {
+		if push {
+			copied[typeURL] = true
+		}
+	}
+	minPushTypes.Lock()
+	defer minPushTypes.Unlock()
+	minPushTypes.types[kind] = copied
+	return nil
+}
+
+// ClearMinPushTypes removes the xDS types required to be pushed for changes of the kind.
+func ClearMinPushTypes(kind config.GroupVersionKind) {
+	minPushTypes.Lock()
+	defer minPushTypes.Unlock()
+	delete(minPushTypes.types, kind)
+}
+
+// minPushTypeRequired returns whether the xDS type is required to be pushed for any of the updated configs.
+func minPushTypeRequired(req *model.PushRequest, typeURL string) bool {
+	minPushTypes.RLock()
+	defer minPushTypes.RUnlock()
+	if len(minPushTypes.types) == 0 {
+		return false
+	}
+	for config := range req.ConfigsUpdated {
+		if minPushTypes.types[config.Kind][typeURL] {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayChangeAffects returns whether the Gateway changes in the push request affect the xDS type,
+// based on the kind of the changes.
+func gatewayChangeAffects(req *model.PushRequest, typeURL string) bool {
+	switch req.GatewayChange {
+	case model.GatewayChangeTLS:
+		return typeURL == v3.ListenerType || typeURL == v3.SecretType
+	case model.GatewayChangeRouting:
+		// With filtering, the clusters of a gateway depend on the routes bound to it.
+		return typeURL == v3.RouteType || (typeURL == v3.ClusterType && features.FilterGatewayClusterConfig)
+	case model.GatewayChangeMetadata:
+		return false
+	default:
+		return true
+	}
+}
+
+// statsOnlyChange returns whether the push request only changes stats filters, which are part of listeners
+// only, so no other xDS type needs to be pushed.
+func statsOnlyChange(req *model.PushRequest) bool {
+	return req.StatsOnly && len(req.ConfigsUpdated) > 0
+}
+
+// PushTypeFor returns the xDS types, keyed by type URL, that the push request requires for the proxy.
+// Each type is decided by the corresponding generator's own check, so this only reflects what the
+// generators would actually send.
+func PushTypeFor(proxy *model.Proxy, req *model.PushRequest) map[string]bool {
+	req = applyClassificationProfile(req)
+	out := map[string]bool{}
+	// Agree with ConfigAffectsProxy on kinds that can not affect the proxy type at all, which the generators
+	// do not check themselves, as they are never asked to push them.
+	if req != nil && len(req.ConfigsUpdated) > 0 {
+		affected := false
+		for config := range req.ConfigsUpdated {
+			if kindAffectsProxyType(config.Kind, proxy.Type) {
+				affected = true
+				break
+			}
+		}
+		if !affected {
+			return out
+		}
+	}
+	if cdsNeedsPush(req, proxy) {
+		out[v3.ClusterType] = true
+	}
+	if EventHasEndpointImpact(req) {
+		out[v3.EndpointType] = true
+	}
+	if ldsNeedsPush(req) {
+		out[v3.ListenerType] = true
+	}
+	if rdsNeedsPush(req, proxy) {
+		out[v3.RouteType] = true
+	}
+	return out
+}
+
+// EvaluatePush returns whether the proxy needs a push for the request and, if so, the xDS types to push,
+// keyed by type URL. It is equivalent to DefaultProxyNeedsPush followed by PushTypeFor, but the types are
+// only computed for affected proxies, which most proxies are not for any single config change.
+func EvaluatePush(proxy *model.Proxy, req *model.PushRequest) (bool, map[string]bool) {
+	if !DefaultProxyNeedsPush(proxy, req) {
+		return false, nil
+	}
+	return true, PushTypeFor(proxy, req)
+}
+
+// EventHasEndpointImpact returns whether the push request may affect endpoints at all, allowing EDS to be
+// skipped for all proxies otherwise. This matches the EDS generator's own check: WorkloadGroup changes,
+// for example, only reach endpoints through the WorkloadEntries created from them, which are pushed separately.
+func EventHasEndpointImpact(req *model.PushRequest) bool {
+	return req == nil || edsRequestNeedsPush(req)
+}
+
+// EndpointDeltaFor returns the change to the endpoints of the service updated by the push request, if only
+// the changed endpoints of the service need to be pushed. Otherwise, all its endpoints must be recomputed.
+func EndpointDeltaFor(req *model.PushRequest, key model.ConfigKey) (*model.EndpointDelta, bool) {
+	if req == nil || req.Full {
+		return nil, false
+	}
+	if _, f := req.ConfigsUpdated[key]; !f {
+		return nil, false
+	}
+	delta := req.EndpointDeltas[key]
+	return delta, delta != nil
+}
+
+// PushTypesForChangeSummary returns the xDS types the push request requires for sidecars and gateways,
+// independent of any specific proxy. This is intended for analysis of what a config change would push.
+func PushTypesForChangeSummary(req *model.PushRequest) (sidecar, gateway map[string]bool) {
+	sidecar = PushTypeFor(&model.Proxy{Type: model.SidecarProxy}, req)
+	gateway = PushTypeFor(&model.Proxy{Type: model.Router}, req)
+	return sidecar, gateway
+}
+
+// FullPushKindsFor returns the Istio config kinds, sorted by name, for which a change pushes all of CDS, EDS,
+// LDS and RDS to proxies of the type, independent of any specific proxy. This is intended for documentation
+// and validation tooling.
+func FullPushKindsFor(proxyType model.NodeType) []config.GroupVersionKind {
+	var out []config.GroupVersionKind
+	for _, s := range collections.Pilot.All() {
+		kind := s.Resource().GroupVersionKind()
+		needsPush, types := EvaluatePush(&model.Proxy{Type: proxyType}, &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: kind, Name: "name", Namespace: "ns"}: {}},
+		})
+		if needsPush && pushesAllTypes(types) {
+			out = append(out, kind)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out
+}
+
+// UnknownFullPushKind is the reason FullPushReason gives for full pushes without updated configs, such as
+// mesh config changes, which may affect anything.
+var UnknownFullPushKind = config.GroupVersionKind{Group: "mesh-config", Kind: "unknown"}
+
+// FullPushReason returns whether the push request pushes all of CDS, EDS, LDS and RDS to sidecars or
+// gateways and, if a single kind among the updated configs does so on its own, that kind. Kinds are checked
+// in order of their names. Requests without updated configs are reported with UnknownFullPushKind. This is
+// intended to find what causes unexpected full pushes.
+func FullPushReason(req *model.PushRequest) (bool, config.GroupVersionKind) {
+	if req == nil || !req.Full {
+		return false, config.GroupVersionKind{}
+	}
+	if len(req.ConfigsUpdated) == 0 {
+		return true, UnknownFullPushKind
+	}
+	if sidecar, gateway := PushTypesForChangeSummary(req); !pushesAllTypes(sidecar) && !pushesAllTypes(gateway) {
+		return false, config.GroupVersionKind{}
+	}
+
+	byKind := map[config.GroupVersionKind]map[model.ConfigKey]struct{}{}
+	for conf := range req.ConfigsUpdated {
+		if byKind[conf.Kind] == nil {
+			byKind[conf.Kind] = map[model.ConfigKey]struct{}{}
+		}
+		byKind[conf.Kind][conf] = struct{}{}
+	}
+	kinds := make([]config.GroupVersionKind, 0, len(byKind))
+	for kind := range byKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return kinds[i].String() < kinds[j].String()
+	})
+	for _, kind := range kinds {
+		// Keep the hints of the request, they may narrow down the types pushed for the kind.
+		single := *req
+		single.ConfigsUpdated = byKind[kind]
+		if sidecar, gateway := PushTypesForChangeSummary(&single); pushesAllTypes(sidecar) || pushesAllTypes(gateway) {
+			return true, kind
+		}
+	}
+	// Only the combination of the updated configs pushes all types.
+	return true, config.GroupVersionKind{}
+}
+
+func pushesAllTypes(types map[string]bool) bool {
+	return types[v3.ClusterType] && types[v3.EndpointType] && types[v3.ListenerType] && types[v3.RouteType]
+}
+
+// PartitionProxiesForPush splits the proxies into those the push request must be sent to according to
+// needsPush, and those it can skip. If the request reaches none of the proxies, it is counted in the
+// zero-target push metric, labeled by the dominant kind of its updated configs.
+func PartitionProxiesForPush(req *model.PushRequest, proxies []*model.Proxy,
+	needsPush func(*model.Proxy, *model.PushRequest) bool) (push, skip []*model.Proxy) {
+	return PartitionOwnedProxiesForPush(req, proxies, needsPush, nil)
+}
+
+// PartitionOwnedProxiesForPush is PartitionProxiesForPush for only the proxies owned returns true for, such as
+// the proxies connected to this istiod instance when the proxies of all instances are known. Other proxies are
+// not evaluated, and are in neither of the returned sets. If owned is nil, all proxies are owned.
+func PartitionOwnedProxiesForPush(req *model.PushRequest, proxies []*model.Proxy,
+	needsPush func(*model.Proxy, *model.PushRequest) bool, owned func(*model.Proxy) bool) (push, skip []*model.Proxy) {
+	for _, proxy := range proxies {
+		if owned != nil && !owned(proxy) {
+			continue
+		}
+		if needsPush(proxy, req) {
+			push = append(push, proxy)
+		} else {
+			skip = append(skip, proxy)
+		}
+	}
+	if len(push) == 0 && len(skip) > 0 {
+		zeroTargetPushes.With(typeTag.Value(dominantKind(req))).Increment()
+	}
+	return push, skip
+}
+
+// SamePushScope returns whether the push requests are pushed to the same proxies of those given. Requests with
+// the same scope can be merged without pushing proxies that neither of them would have pushed on its own.
+func SamePushScope(a, b *model.PushRequest, proxies []*model.Proxy) bool {
+	for _, proxy := range proxies {
+		if DefaultProxyNeedsPush(proxy, a) != DefaultProxyNeedsPush(proxy, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// dominantKind returns the kind of most of the updated configs of the push request, the first by name on
+// ties, or "unknown" if there are none.
+func dominantKind(req *model.PushRequest) string {
+	counts := map[string]int{}
+	for conf := range req.ConfigsUpdated {
+		counts[conf.Kind.Kind]++
+	}
+	dominant := "unknown"
+	for kind, count := range counts {
+		if count > counts[dominant] || (count == counts[dominant] && kind < dominant) {
+			dominant = kind
+		}
+	}
+	return dominant
+}
+
+// AffectedClusters returns the names of the outbound clusters of the proxy that may have been added or
+// modified by the ServiceEntry changes in the push request, derived from the hosts and ports of the changed
+// services and the subsets of their destination rules. This allows pushing only those clusters.
+// Clusters of ports that were removed can not be derived from the push context and must be found by
+// comparing against the clusters previously sent. The second return value is false if the clusters can
+// not be derived, for example because the request is not limited to ServiceEntry changes or a changed
+// service was deleted, in which case all clusters must be pushed.
+func AffectedClusters(proxy *model.Proxy, req *model.PushRequest) (map[string]struct{}, bool) {
+	if req == nil || req.Push == nil || len(req.ConfigsUpdated) == 0 {
+		return nil, false
+	}
+	clusters := map[string]struct{}{}
+	for config := range req.ConfigsUpdated {
+		if config.Kind != gvk.ServiceEntry {
+			return nil, false
+		}
+		svc := req.Push.ServiceIndex.HostnameAndNamespace[host.Name(config.Name)][config.Namespace]
+		if svc == nil {
+			return nil, false
+		}
+		var subsets []*networking.Subset
+		if dr := req.Push.DestinationRule(proxy, svc); dr != nil {
+			subsets = dr.Spec.(*networking.DestinationRule).Subsets
+		}
+		for _, port := range svc.Ports {
+			clusters[model.BuildSubsetKey(model.TrafficDirectionOutbound, "", svc.Hostname, port.Port)] = struct{}{}
+			for _, subset := range subsets {
+				clusters[model.BuildSubsetKey(model.TrafficDirectionOutbound, subset.Name, svc.Hostname, port.Port)] = struct{}{}
+			}
+		}
+	}
+	return clusters, true
+}
+
+// skippedPushCapacity bounds the number of skipped pushes remembered for a proxy without a SidecarScope.
+const skippedPushCapacity = 8
+
+// skippedPushes is a ring buffer of the most recent pushes skipped for a proxy. It is only accessed from
+// the goroutine pushing to the connection, so it is not synchronized.
+type skippedPushes struct {
+	requests [skippedPushCapacity]*model.PushRequest
+	next     int
+	size     int
+}
+
+// add remembers the skipped push, evicting the oldest one if the buffer is full.
+func (sp *skippedPushes) add(req *model.PushRequest) {
+	sp.requests[sp.next] = req
+	sp.next = (sp.next + 1) % skippedPushCapacity
+	if sp.size < skippedPushCapacity {
+		sp.size++
+	}
+}
+
+// catchUp re-evaluates the skipped pushes for the proxy, which is expected to have its SidecarScope set
+// by now, and clears them. It returns the merge of the pushes that apply to the proxy after all, in the
+// order they were skipped, or nil if there are none.
+func (sp *skippedPushes) catchUp(proxy *model.Proxy, needsPush func(*model.Proxy, *model.PushRequest) bool) *model.PushRequest {
+	var out *model.PushRequest
+	start := (sp.next - sp.size + skippedPushCapacity) % skippedPushCapacity
+	for i := 0; i < sp.size; i++ {
+		req := sp.requests[(start+i)%skippedPushCapacity]
+		if needsPush(proxy, req) {
+			out = out.Merge(req)
+		}
+	}
+	*sp = skippedPushes{}
+	return out
+}
+
+// configGenerationTracker tracks the newest generation seen for each config, so that updates delivered out of
+// order, such as informer events for an older generation, can be dropped rather than pushed again.
+type configGenerationTracker struct {
+	mutex       sync.Mutex
+	generations map[model.ConfigKey]int64
+}
+
+func newConfigGenerationTracker() *configGenerationTracker {
+	return &configGenerationTracker{generations: map[model.ConfigKey]int64{}}
+}
+
+// dropStale removes the configs updated for a generation older than the newest one seen from the request,
+// and records the generations of the others. Configs updated without a generation, such as deletions, are
+// forgotten, as a config created again starts over from the first generation. If all updated configs are
+// stale, nil is returned, as a request without updated configs would push all configs.
+func (t *configGenerationTracker) dropStale(req *model.PushRequest) *model.PushRequest {
+	if t == nil || req == nil || len(req.ConfigsUpdated) == 0 {
+		return req
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var stale []model.ConfigKey
+	for conf := range req.ConfigsUpdated {
+		generation, f := req.ConfigGenerations[conf]
+		if !f {
+			delete(t.generations, conf)
+			continue
+		}
+		if generation < t.generations[conf] {
+			stale = append(stale, conf)
+			continue
+		}
+		t.generations[conf] = generation
+	}
+	if len(stale) == 0 {
+		return req
+	}
+	adsLog.Debugf("Dropping stale config updates %v (correlation id %q)", stale, req.CorrelationID)
+	if len(stale) == len(req.ConfigsUpdated) {
+		return nil
+	}
+	// Do not modify the request, it may be shared by the producer.
+	out := *req
+	out.ConfigsUpdated = make(map[model.ConfigKey]struct{}, len(req.ConfigsUpdated)-len(stale))
+	for conf := range req.ConfigsUpdated {
+		out.ConfigsUpdated[conf] = struct{}{}
+	}
+	for _, conf := range stale {
+		delete(out.ConfigsUpdated, conf)
+	}
+	return &out
+}
+
+// pushAccountingRetention bounds how long push times are kept for each proxy. Windows passed to
+// HotProxies longer than this only see the retained pushes.
+const pushAccountingRetention = 10 * time.Minute
+
+// proxyPushAccounting tracks the times of recent pushes to each proxy, keyed by proxy ID, so that
+// proxies receiving a storm of pushes can be detected.
+type proxyPushAccounting struct {
+	mutex  sync.Mutex
+	pushes map[string][]time.Time
+}
+
+func newProxyPushAccounting() *proxyPushAccounting {
+	return &proxyPushAccounting{pushes: map[string][]time.Time{}}
+}
+
+// record notes a push to the proxy at the given time, dropping pushes older than the retention.
+func (a *proxyPushAccounting) record(proxyID string, now time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.pushes[proxyID] = append(pushesSince(a.pushes[proxyID], now.Add(-pushAccountingRetention)), now)
+}
+
+// remove drops all pushes recorded for the proxy.
+func (a *proxyPushAccounting) remove(proxyID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.pushes, proxyID)
+}
+
+// hotProxies returns the sorted IDs of proxies with at least threshold pushes in the window ending at now.
+func (a *proxyPushAccounting) hotProxies(threshold int, window time.Duration, now time.Time) []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var out []string
+	for id, pushes := range a.pushes {
+		if len(pushesSince(pushes, now.Add(-window))) >= threshold {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// pushesSince returns the suffix of the ordered push times that are not before the cutoff.
+func pushesSince(pushes []time.Time, cutoff time.Time) []time.Time {
+	i := sort.Search(len(pushes), func(i int) bool {
+		return !pushes[i].Before(cutoff)
+	})
+	return pushes[i:]
+}
+
+// HotProxies returns the IDs of connected proxies that needed at least threshold pushes within the
+// most recent window. This is intended to detect proxies that are subject to push storms.
+func (s *DiscoveryServer) HotProxies(threshold int, window time.Duration) []string {
+	return s.pushAccounting.hotProxies(threshold, window, time.Now())
+}
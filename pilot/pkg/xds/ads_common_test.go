@@ -16,11 +16,30 @@ package xds
 
 import (
 	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
 	"strconv"
 	"testing"
+	"time"
 
+	"go.opencensus.io/stats/view"
+	"golang.org/x/time/rate"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	securityBeta "istio.io/api/security/v1beta1"
+	selectorpb "istio.io/api/type/v1beta1"
+	"istio.io/istio/pilot/pkg/config/memory"
 	model "istio.io/istio/pilot/pkg/model"
+	memregistry "istio.io/istio/pilot/pkg/serviceregistry/memory"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/spiffe"
 )
@@ -51,6 +70,12 @@ func TestProxyNeedsPush(t *testing.T) {
 		SidecarScope: &model.SidecarScope{Name: generalName, Namespace: nsName, RootNamespace: nsRoot},
 	}
 	gateway := &model.Proxy{Type: model.Router}
+	scopedGateway := &model.Proxy{
+		Type:         model.Router,
+		SidecarScope: &model.SidecarScope{Name: generalName, Namespace: nsName, RootNamespace: nsRoot},
+	}
+	// Only virtual services bound to the served gateways and visible from its namespace are dependencies.
+	scopedGateway.SidecarScope.AddConfigDependencies(model.ConfigKey{Kind: gvk.VirtualService, Name: vsName, Namespace: nsName})
 
 	sidecarScopeKindNames := map[config.GroupVersionKind]string{
 		gvk.ServiceEntry: svcName, gvk.VirtualService: vsName, gvk.DestinationRule: drName,
@@ -108,6 +133,33 @@ func TestProxyNeedsPush(t *testing.T) {
 			{Kind: gvk.ServiceEntry, Name: svcName + invalidNameSuffix, Namespace: nsName}:   {},
 		}, false},
 		{"empty configsUpdated for sidecar", sidecar, nil, true},
+		{"sidecar change with unmatched config for sidecar in same namespace", sidecar, map[model.ConfigKey]struct{}{
+			{Kind: gvk.Sidecar, Name: scName, Namespace: nsName}:                           {},
+			{Kind: gvk.ServiceEntry, Name: svcName + invalidNameSuffix, Namespace: nsName}: {},
+		}, true},
+		{"sidecar change in root namespace with unmatched config for sidecar", sidecar, map[model.ConfigKey]struct{}{
+			{Kind: gvk.Sidecar, Name: scName, Namespace: nsRoot}:                           {},
+			{Kind: gvk.ServiceEntry, Name: svcName + invalidNameSuffix, Namespace: nsName}: {},
+		}, true},
+		{"sidecar change with unmatched config for sidecar in different namespace", sidecar, map[model.ConfigKey]struct{}{
+			{Kind: gvk.Sidecar, Name: scName, Namespace: "invalid-namespace"}:              {},
+			{Kind: gvk.ServiceEntry, Name: svcName + invalidNameSuffix, Namespace: nsName}: {},
+		}, false},
+		{"bound virtual service for gateway", scopedGateway, map[model.ConfigKey]struct{}{
+			{Kind: gvk.VirtualService, Name: vsName, Namespace: nsName}: {},
+		}, true},
+		{"virtual service in disallowed namespace for gateway", scopedGateway, map[model.ConfigKey]struct{}{
+			{Kind: gvk.VirtualService, Name: vsName, Namespace: "invalid-namespace"}: {},
+		}, false},
+		{"unbound virtual service for gateway", scopedGateway, map[model.ConfigKey]struct{}{
+			{Kind: gvk.VirtualService, Name: vsName + invalidNameSuffix, Namespace: nsName}: {},
+		}, false},
+		{"destination rule for scoped gateway", scopedGateway, map[model.ConfigKey]struct{}{
+			{Kind: gvk.DestinationRule, Name: drName + invalidNameSuffix, Namespace: nsName}: {},
+		}, true},
+		{"virtual service for unscoped gateway", gateway, map[model.ConfigKey]struct{}{
+			{Kind: gvk.VirtualService, Name: vsName + invalidNameSuffix, Namespace: nsName}: {},
+		}, true},
 	}
 
 	for kind, name := range sidecarScopeKindNames {
@@ -168,29 +220,2634 @@ func TestProxyNeedsPush(t *testing.T) {
 			if got != tt.want {
 				t.Fatalf("Got needs push = %v, expected %v", got, tt.want)
 			}
+
+			for _, full := range []bool{false, true} {
+				req := &model.PushRequest{Full: full, ConfigsUpdated: tt.configs}
+				var wantTypes map[string]bool
+				if tt.want {
+					wantTypes = PushTypeFor(tt.proxy, req)
+				}
+				needsPush, types := EvaluatePush(tt.proxy, req)
+				if needsPush != tt.want || !reflect.DeepEqual(types, wantTypes) {
+					t.Fatalf("full=%v: EvaluatePush() = %v, %v, expected %v, %v", full, needsPush, types, tt.want, wantTypes)
+				}
+			}
 		})
 	}
 }
 
-func BenchmarkListEquals(b *testing.B) {
-	size := 100
-	var l []string
-	for i := 0; i < size; i++ {
-		l = append(l, strconv.Itoa(i))
+func TestProxyNeedsPushRouterWithoutScope(t *testing.T) {
+	// Routers may not have a SidecarScope assigned yet, scoping must not rely on it.
+	router := &model.Proxy{Type: model.Router}
+	for _, kind := range []config.GroupVersionKind{gvk.ServiceEntry, gvk.VirtualService, gvk.DestinationRule} {
+		t.Run(kind.Kind, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: kind, Name: "name", Namespace: "ns"}: {}},
+			}
+			if !DefaultProxyNeedsPush(router, req) {
+				t.Fatalf("expected router without scope to be pushed for %s", kind.Kind)
+			}
+		})
 	}
-	var equal []string
-	for i := 0; i < size; i++ {
-		equal = append(equal, strconv.Itoa(i))
+}
+
+func TestPushTypesForChangeSummary(t *testing.T) {
+	all := map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.ListenerType: true, v3.RouteType: true}
+	cases := []struct {
+		name        string
+		full        bool
+		kind        config.GroupVersionKind
+		wantSidecar map[string]bool
+		wantGateway map[string]bool
+	}{
+		{
+			name:        "gateway",
+			full:        true,
+			kind:        gvk.Gateway,
+			wantSidecar: map[string]bool{},
+			wantGateway: map[string]bool{v3.ClusterType: true, v3.ListenerType: true, v3.RouteType: true},
+		},
+		{
+			name:        "sidecar",
+			full:        true,
+			kind:        gvk.Sidecar,
+			wantSidecar: all,
+			wantGateway: map[string]bool{},
+		},
+		{
+			name:        "workload group",
+			full:        true,
+			kind:        gvk.WorkloadGroup,
+			wantSidecar: map[string]bool{},
+			wantGateway: map[string]bool{},
+		},
+		{
+			name:        "virtual service",
+			full:        true,
+			kind:        gvk.VirtualService,
+			wantSidecar: map[string]bool{v3.ClusterType: true, v3.ListenerType: true, v3.RouteType: true},
+			wantGateway: map[string]bool{v3.ClusterType: true, v3.ListenerType: true, v3.RouteType: true},
+		},
+		{
+			name:        "destination rule",
+			full:        true,
+			kind:        gvk.DestinationRule,
+			wantSidecar: map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.RouteType: true},
+			wantGateway: map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.RouteType: true},
+		},
+		{
+			name:        "authorization policy",
+			full:        true,
+			kind:        gvk.AuthorizationPolicy,
+			wantSidecar: map[string]bool{v3.ListenerType: true},
+			wantGateway: map[string]bool{v3.ListenerType: true},
+		},
+		{
+			name:        "service entry",
+			full:        true,
+			kind:        gvk.ServiceEntry,
+			wantSidecar: all,
+			wantGateway: all,
+		},
+		{
+			name:        "incremental service entry",
+			full:        false,
+			kind:        gvk.ServiceEntry,
+			wantSidecar: map[string]bool{v3.EndpointType: true},
+			wantGateway: map[string]bool{v3.EndpointType: true},
+		},
+		{
+			name:        "secret",
+			full:        true,
+			kind:        gvk.Secret,
+			wantSidecar: map[string]bool{},
+			wantGateway: map[string]bool{},
+		},
 	}
-	var notEqual []string
-	for i := 0; i < size; i++ {
-		notEqual = append(notEqual, strconv.Itoa(i))
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           tt.full,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: tt.kind, Name: "name", Namespace: "ns"}: {}},
+			}
+			sidecar, gateway := PushTypesForChangeSummary(req)
+			if !reflect.DeepEqual(sidecar, tt.wantSidecar) {
+				t.Errorf("sidecar: got %v, want %v", sidecar, tt.wantSidecar)
+			}
+			if !reflect.DeepEqual(gateway, tt.wantGateway) {
+				t.Errorf("gateway: got %v, want %v", gateway, tt.wantGateway)
+			}
+		})
 	}
-	notEqual[size-1] = "z"
 
-	for n := 0; n < b.N; n++ {
-		listEqualUnordered(l, equal)
-		listEqualUnordered(l, notEqual)
+	t.Run("empty configsUpdated", func(t *testing.T) {
+		sidecar, gateway := PushTypesForChangeSummary(&model.PushRequest{Full: true})
+		if !reflect.DeepEqual(sidecar, all) || !reflect.DeepEqual(gateway, all) {
+			t.Errorf("expected all types, got sidecar %v gateway %v", sidecar, gateway)
+		}
+	})
+}
+
+func TestFullPushReason(t *testing.T) {
+	unknownKind := config.GroupVersionKind{Group: "unknown.istio.io", Version: "v1", Kind: "Unknown"}
+	update := func(full bool, kinds ...config.GroupVersionKind) *model.PushRequest {
+		req := &model.PushRequest{Full: full, ConfigsUpdated: map[model.ConfigKey]struct{}{}}
+		for _, kind := range kinds {
+			req.ConfigsUpdated[model.ConfigKey{Kind: kind, Name: "name", Namespace: "ns"}] = struct{}{}
+		}
+		return req
+	}
+	cases := []struct {
+		name     string
+		req      *model.PushRequest
+		wantFull bool
+		wantKind config.GroupVersionKind
+	}{
+		{"empty", &model.PushRequest{Full: true}, true, UnknownFullPushKind},
+		{"incremental", &model.PushRequest{}, false, config.GroupVersionKind{}},
+		{"unknown kind", update(true, unknownKind), true, unknownKind},
+		{"unknown kind among others", update(true, gvk.AuthorizationPolicy, unknownKind), true, unknownKind},
+		{"classified kind", update(true, gvk.VirtualService), false, config.GroupVersionKind{}},
+		{"combination of kinds", update(true, gvk.VirtualService, gvk.DestinationRule), true, config.GroupVersionKind{}},
+		{"incremental unknown kind", update(false, unknownKind), false, config.GroupVersionKind{}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			full, kind := FullPushReason(tt.req)
+			if full != tt.wantFull || kind != tt.wantKind {
+				t.Fatalf("FullPushReason() = %v, %v, want %v, %v", full, kind, tt.wantFull, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestProxyNeedsPushAgreesWithPushTypes(t *testing.T) {
+	// A proxy needs a push for a full push request exactly when some xDS type would be pushed to it. Secrets
+	// are only pushed through SDS, which PushTypeFor does not cover.
+	kinds := []config.GroupVersionKind{gvk.Secret}
+	for _, s := range collections.PilotServiceApi.All() {
+		kinds = append(kinds, s.Resource().GroupVersionKind())
+	}
+	for _, kind := range kinds {
+		for _, nodeType := range []model.NodeType{model.SidecarProxy, model.Router} {
+			t.Run(fmt.Sprintf("%s for %s", kind.Kind, nodeType), func(t *testing.T) {
+				proxy := &model.Proxy{Type: nodeType}
+				req := &model.PushRequest{
+					Full:           true,
+					ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: kind, Name: "name", Namespace: "ns"}: {}},
+				}
+				types := PushTypeFor(proxy, req)
+				pushed := len(types) > 0 || needsUpdate(proxy, req)
+				if needsPush := DefaultProxyNeedsPush(proxy, req); needsPush != pushed {
+					t.Fatalf("DefaultProxyNeedsPush() = %v, but pushed types are %v (SDS %v)", needsPush, types, needsUpdate(proxy, req))
+				}
+			})
+		}
+	}
+}
+
+// randomPushRequest returns a push request as its producers would create it: incremental updates only for
+// services, and hints only describing the kinds they apply to.
+func randomPushRequest(r *rand.Rand, kinds []config.GroupVersionKind) *model.PushRequest {
+	req := &model.PushRequest{Full: r.Intn(4) != 0}
+	n := r.Intn(4)
+	if !req.Full {
+		n++
+	}
+	if n > 0 {
+		req.ConfigsUpdated = map[model.ConfigKey]struct{}{}
+	}
+	statsOnly := n > 0
+	for i := 0; i < n; i++ {
+		kind := gvk.ServiceEntry
+		if req.Full {
+			kind = kinds[r.Intn(len(kinds))]
+		}
+		key := model.ConfigKey{Kind: kind, Name: "name" + strconv.Itoa(i), Namespace: "ns"}
+		req.ConfigsUpdated[key] = struct{}{}
+		if kind == gvk.ServiceEntry && r.Intn(2) == 0 {
+			if req.DrainingServices == nil {
+				req.DrainingServices = map[model.ConfigKey]struct{}{}
+			}
+			req.DrainingServices[key] = struct{}{}
+		}
+		statsOnly = statsOnly && kind == gvk.EnvoyFilter
+	}
+	req.GatewayChange = model.GatewayChangeKind(r.Intn(3))
+	req.EnvoyFilterTarget = model.EnvoyFilterTarget(r.Intn(3))
+	req.DestinationRuleChange = model.DestinationRuleChangeKind(r.Intn(2))
+	req.StatsOnly = statsOnly && r.Intn(2) == 0
+	return req
+}
+
+func TestProxyNeedsPushImpliesPushTypes(t *testing.T) {
+	// Whenever a proxy needs a push, some xDS type is pushed to it. The converse does not hold, as PushTypeFor
+	// only depends on the proxy type, not on what the proxy's scope depends on.
+	kinds := []config.GroupVersionKind{gvk.Secret}
+	for _, s := range collections.Pilot.All() {
+		kinds = append(kinds, s.Resource().GroupVersionKind())
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		req := randomPushRequest(r, kinds)
+		for _, nodeType := range []model.NodeType{model.SidecarProxy, model.Router} {
+			proxy := &model.Proxy{Type: nodeType}
+			if !DefaultProxyNeedsPush(proxy, req) {
+				continue
+			}
+			if types := PushTypeFor(proxy, req); len(types) == 0 && !needsUpdate(proxy, req) {
+				t.Fatalf("%s: DefaultProxyNeedsPush() = true, but no types are pushed for %+v", nodeType, req)
+			}
+		}
+	}
+}
+
+func TestDrainingIncrementalPushSkipped(t *testing.T) {
+	key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "drained.com", Namespace: "ns"}
+	req := &model.PushRequest{
+		ConfigsUpdated:   map[model.ConfigKey]struct{}{key: {}},
+		DrainingServices: map[model.ConfigKey]struct{}{key: {}},
+	}
+	proxy := &model.Proxy{ID: "sidecar", Type: model.SidecarProxy}
+	if DefaultProxyNeedsPush(proxy, req) {
+		t.Fatalf("expected an incremental push of only draining services to be skipped")
+	}
+	if got, want := PushSkipReport([]*model.Proxy{proxy}, req), map[string]PushSkipReason{"sidecar": SkipReasonNoEndpointChange}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("PushSkipReport() = %v, want %v", got, want)
+	}
+	req.Full = true
+	if !DefaultProxyNeedsPush(proxy, req) {
+		t.Fatalf("expected a full push of draining services to push clusters")
+	}
+}
+
+func TestFullPushKindsFor(t *testing.T) {
+	contains := func(kinds []config.GroupVersionKind, kind config.GroupVersionKind) bool {
+		for _, k := range kinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}
+	sidecar := FullPushKindsFor(model.SidecarProxy)
+	gateway := FullPushKindsFor(model.Router)
+	for _, kind := range []config.GroupVersionKind{gvk.ServiceEntry, gvk.EnvoyFilter, gvk.Sidecar} {
+		if !contains(sidecar, kind) {
+			t.Errorf("expected %v to full push sidecars, got %v", kind, sidecar)
+		}
+	}
+	if !contains(gateway, gvk.ServiceEntry) {
+		t.Errorf("expected %v to full push gateways, got %v", gvk.ServiceEntry, gateway)
+	}
+	for _, kind := range []config.GroupVersionKind{gvk.Sidecar, gvk.WorkloadGroup} {
+		if contains(gateway, kind) {
+			t.Errorf("expected %v not to full push gateways, got %v", kind, gateway)
+		}
+	}
+	if !sort.SliceIsSorted(sidecar, func(i, j int) bool { return sidecar[i].String() < sidecar[j].String() }) {
+		t.Errorf("expected kinds to be sorted, got %v", sidecar)
+	}
+}
+
+func TestGatewayChangePushTypes(t *testing.T) {
+	router := &model.Proxy{Type: model.Router}
+	cases := []struct {
+		name       string
+		change     model.GatewayChangeKind
+		want       map[string]bool
+		wantSecret bool
+	}{
+		{
+			name:       "tls",
+			change:     model.GatewayChangeTLS,
+			want:       map[string]bool{v3.ListenerType: true},
+			wantSecret: true,
+		},
+		{
+			name:   "routing",
+			change: model.GatewayChangeRouting,
+			want:   map[string]bool{v3.RouteType: true},
+		},
+		{
+			name:   "metadata",
+			change: model.GatewayChangeMetadata,
+			want:   map[string]bool{},
+		},
+		{
+			name:   "structural",
+			change: model.GatewayChangeStructural,
+			want:   map[string]bool{v3.ClusterType: true, v3.ListenerType: true, v3.RouteType: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.Gateway, Name: "gw", Namespace: "ns"}: {}},
+				GatewayChange:  tt.change,
+			}
+			if got := PushTypeFor(router, req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PushTypeFor() = %v, want %v", got, tt.want)
+			}
+			if got := needsUpdate(router, req); got != tt.wantSecret {
+				t.Errorf("needsUpdate() = %v, want %v", got, tt.wantSecret)
+			}
+		})
+	}
+}
+
+// newTestEnvironment creates an environment without services, backed by the config store.
+func newTestEnvironment(store model.ConfigStore) *model.Environment {
+	return &model.Environment{
+		ServiceDiscovery: memregistry.NewServiceDiscovery(nil),
+		IstioConfigStore: model.MakeIstioStore(store),
+		Watcher:          mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-system"}),
+	}
+}
+
+// newTestPushContext creates the configs in the store of the environment, and initializes a push context
+// updated from the previous one, if any.
+func newTestPushContext(t *testing.T, env *model.Environment, prev *model.PushContext, configs ...config.Config) *model.PushContext {
+	t.Helper()
+	for _, c := range configs {
+		if _, err := env.IstioConfigStore.Create(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	push := model.NewPushContext()
+	if err := push.InitContext(env, prev, nil); err != nil {
+		t.Fatal(err)
+	}
+	return push
+}
+
+// sumValue returns the value of the sum metric, limited to the row with the type label if one is given.
+func sumValue(t *testing.T, name, typeLabel string) float64 {
+	t.Helper()
+	data, err := view.RetrieveData(name)
+	if err != nil {
+		t.Fatalf("failed to get value for counter: %v", err)
+	}
+	for _, row := range data {
+		if typeLabel == "" {
+			return row.Data.(*view.SumData).Value
+		}
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "type" && tag.Value == typeLabel {
+				return row.Data.(*view.SumData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestVirtualServiceScope(t *testing.T) {
+	vs := func(name, namespace string, gateways ...string) config.Config {
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: name, Namespace: namespace},
+			Spec: &networking.VirtualService{
+				Hosts:    []string{name + "." + namespace + ".com"},
+				Gateways: gateways,
+				Http: []*networking.HTTPRoute{{
+					Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: name + ".com"}}},
+				}},
+			},
+		}
+	}
+	push := newTestPushContext(t, newTestEnvironment(memory.Make(collections.Pilot)), nil,
+		config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: "gw", Namespace: "istio-system"},
+			Spec: &networking.Gateway{Servers: []*networking.Server{{
+				Port:  &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"},
+				Hosts: []string{"allowed/*"},
+			}}},
+		},
+		vs("gateway-only", "ns", "my-gw"),
+		vs("mesh", "ns"),
+		vs("mesh-and-gw", "ns", "mesh", "my-gw"),
+		vs("vs", "allowed", "istio-system/gw"),
+		vs("vs", "disallowed", "istio-system/gw"),
+	)
+	sidecar := &model.Proxy{
+		Type:            model.SidecarProxy,
+		ConfigNamespace: "ns",
+		Metadata:        &model.NodeMetadata{Namespace: "ns"},
+		SidecarScope:    model.DefaultSidecarScopeForNamespace(push, "ns"),
+	}
+	gateway := &model.Proxy{Type: model.Router, ConfigNamespace: "istio-system", Metadata: &model.NodeMetadata{}}
+	gateway.SetSidecarScope(push)
+	gateway.SetGatewaysForProxy(push)
+
+	cases := []struct {
+		name      string
+		proxy     *model.Proxy
+		vs        string
+		namespace string
+		want      bool
+	}{
+		{"sidecar gateway only", sidecar, "gateway-only", "ns", false},
+		{"sidecar mesh", sidecar, "mesh", "ns", true},
+		{"sidecar mesh and gateway", sidecar, "mesh-and-gw", "ns", true},
+		{"gateway allowed namespace", gateway, "vs", "allowed", true},
+		// The server hosts of the gateway do not allow the namespace, so the virtual service is not bound to it.
+		{"gateway disallowed namespace", gateway, "vs", "disallowed", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           true,
+				Push:           push,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: tt.vs, Namespace: tt.namespace}: {}},
+			}
+			if got := DefaultProxyNeedsPush(tt.proxy, req); got != tt.want {
+				t.Fatalf("DefaultProxyNeedsPush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeerAuthenticationPortLevelScope(t *testing.T) {
+	store := memory.Make(collections.Pilot)
+	env := newTestEnvironment(store)
+	old := newTestPushContext(t, env, nil)
+	if _, err := store.Create(config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.PeerAuthentication, Name: "port-level", Namespace: "ns"},
+		Spec: &securityBeta.PeerAuthentication{
+			Selector: &selectorpb.WorkloadSelector{MatchLabels: map[string]string{"app": "foo"}},
+			PortLevelMtls: map[uint32]*securityBeta.PeerAuthentication_MutualTLS{
+				8080: {Mode: securityBeta.PeerAuthentication_MutualTLS_STRICT},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	req := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.PeerAuthentication, Name: "port-level", Namespace: "ns"}: {}},
+	}
+	req.Push = model.NewPushContext()
+	if err := req.Push.InitContext(env, old, req); err != nil {
+		t.Fatal(err)
+	}
+
+	sidecar := func(namespace string, labels map[string]string, port uint32) *model.Proxy {
+		svc := &model.Service{Hostname: "foo.ns.svc.cluster.local", Attributes: model.ServiceAttributes{Namespace: namespace}}
+		return &model.Proxy{
+			Type:     model.SidecarProxy,
+			Metadata: &model.NodeMetadata{Namespace: namespace, Labels: labels},
+			ServiceInstances: []*model.ServiceInstance{{
+				Service:     svc,
+				ServicePort: &model.Port{Name: "http", Port: int(port), Protocol: protocol.HTTP},
+				Endpoint:    &model.IstioEndpoint{EndpointPort: port},
+			}},
+		}
+	}
+	cases := []struct {
+		name  string
+		proxy *model.Proxy
+		want  bool
+	}{
+		{"selected with targeted port", sidecar("ns", map[string]string{"app": "foo"}, 8080), true},
+		// The per-port passthrough filter chain is built even if the proxy does not serve on the port.
+		{"selected without targeted port", sidecar("ns", map[string]string{"app": "foo"}, 9090), true},
+		{"not selected with targeted port", sidecar("ns", map[string]string{"app": "bar"}, 8080), false},
+		{"other namespace", sidecar("other", map[string]string{"app": "foo"}, 8080), false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConfigAffectsProxy(req, tt.proxy); got != tt.want {
+				t.Fatalf("ConfigAffectsProxy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeerAuthenticationOutboundOnlySidecar(t *testing.T) {
+	env := newTestEnvironment(memory.Make(collections.Pilot))
+	old := newTestPushContext(t, env, nil)
+	var policies []config.Config
+	for name, selector := range map[string]*selectorpb.WorkloadSelector{
+		"workload":  {MatchLabels: map[string]string{"app": "foo"}},
+		"namespace": nil,
+	} {
+		policies = append(policies, config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.PeerAuthentication, Name: name, Namespace: "ns"},
+			Spec: &securityBeta.PeerAuthentication{
+				Selector: selector,
+				Mtls:     &securityBeta.PeerAuthentication_MutualTLS{Mode: securityBeta.PeerAuthentication_MutualTLS_STRICT},
+			},
+		})
+	}
+	push := newTestPushContext(t, env, old, policies...)
+
+	sidecar := func(interceptionMode string, customIngress bool) *model.Proxy {
+		return &model.Proxy{
+			Type:         model.SidecarProxy,
+			Metadata:     &model.NodeMetadata{Namespace: "ns", Labels: map[string]string{"app": "foo"}, InterceptionMode: model.TrafficInterceptionMode(interceptionMode)},
+			SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system", HasCustomIngressListeners: customIngress},
+		}
+	}
+	cases := []struct {
+		name          string
+		proxy         *model.Proxy
+		wantWorkload  bool
+		wantNamespace bool
+	}{
+		{"outbound only", sidecar("NONE", false), false, true},
+		{"no interception with ingress listeners", sidecar("NONE", true), true, true},
+		{"redirected", sidecar("REDIRECT", false), true, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, want := range map[string]bool{"workload": tt.wantWorkload, "namespace": tt.wantNamespace} {
+				req := &model.PushRequest{
+					Full:           true,
+					Push:           push,
+					ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.PeerAuthentication, Name: name, Namespace: "ns"}: {}},
+				}
+				if got := ConfigAffectsProxy(req, tt.proxy); got != want {
+					t.Errorf("%s policy: ConfigAffectsProxy() = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSidecarPortLevelImports(t *testing.T) {
+	env := newTestEnvironment(memory.Make(collections.Pilot))
+	env.ServiceDiscovery = memregistry.NewServiceDiscovery([]*model.Service{{
+		Hostname:   "svc.ns.svc.cluster.local",
+		Ports:      model.PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}, {Name: "tcp", Port: 8080, Protocol: protocol.TCP}},
+		Attributes: model.ServiceAttributes{Namespace: "ns"},
+	}})
+	push := newTestPushContext(t, env, nil, config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.Sidecar, Name: "sidecar", Namespace: "ns"},
+		Spec: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{
+				Port:  &networking.Port{Number: 9080, Protocol: "HTTP", Name: "http"},
+				Hosts: []string{"*/*"},
+			}},
+		},
+	})
+	proxy := &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: "ns", Metadata: &model.NodeMetadata{Namespace: "ns"}}
+	proxy.SetSidecarScope(push)
+
+	key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns.svc.cluster.local", Namespace: "ns"}
+	cases := []struct {
+		name  string
+		ports map[model.ConfigKey][]int
+		want  bool
+	}{
+		{"imported port", map[model.ConfigKey][]int{key: {9080}}, true},
+		{"port not imported", map[model.ConfigKey][]int{key: {8080}}, false},
+		{"one of the ports imported", map[model.ConfigKey][]int{key: {8080, 9080}}, true},
+		{"unknown ports", nil, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Push:           push,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{key: {}},
+				ServicePorts:   tt.ports,
+			}
+			if got := ConfigAffectsProxy(req, proxy); got != tt.want {
+				t.Fatalf("ConfigAffectsProxy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceEntryHostAndAddressChange(t *testing.T) {
+	// A ServiceEntry that renames its host and changes its VIP in one update is
+	// delivered as a delete of the old host merged with an add of the new one.
+	oldKey := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "old.example.com", Namespace: "ns"}
+	newKey := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "new.example.com", Namespace: "ns"}
+	pushFor := func(hostname host.Name, address string) *model.PushContext {
+		env := newTestEnvironment(memory.Make(collections.Pilot))
+		env.ServiceDiscovery = memregistry.NewServiceDiscovery([]*model.Service{{
+			Hostname:   hostname,
+			Address:    address,
+			Ports:      model.PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}},
+			Attributes: model.ServiceAttributes{Namespace: "ns"},
+		}})
+		return newTestPushContext(t, env, nil)
+	}
+	oldPush := pushFor("old.example.com", "10.0.0.1")
+	newPush := pushFor("new.example.com", "10.0.0.2")
+
+	deleted := &model.PushRequest{
+		Full:           true,
+		Push:           newPush,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{oldKey: {}},
+	}
+	added := &model.PushRequest{
+		Full:           true,
+		Push:           newPush,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{newKey: {}},
+		ServicePorts:   map[model.ConfigKey][]int{newKey: {9080}},
+	}
+	req := deleted.Merge(added)
+	if len(req.ConfigsUpdated) != 2 {
+		t.Fatalf("ConfigsUpdated = %v, want both hosts", req.ConfigsUpdated)
+	}
+
+	want := map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.ListenerType: true, v3.RouteType: true}
+	gateway := &model.Proxy{Type: model.Router}
+	if got := PushTypeFor(gateway, req); !reflect.DeepEqual(got, want) {
+		t.Fatalf("gateway PushTypeFor() = %v, want %v", got, want)
+	}
+
+	sidecar := &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: "ns", Metadata: &model.NodeMetadata{Namespace: "ns"}}
+	sidecar.SetSidecarScope(oldPush)
+	sidecar.SetSidecarScope(newPush)
+	if !ConfigAffectsProxy(req, sidecar) {
+		t.Fatalf("expected the combined change to affect the sidecar")
+	}
+	if got := PushTypeFor(sidecar, req); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sidecar PushTypeFor() = %v, want %v", got, want)
+	}
+}
+
+func TestServiceEntryScopeOutboundTrafficPolicy(t *testing.T) {
+	// Whatever the outbound traffic policy, the outbound configuration of a sidecar only includes the services
+	// it imports. Traffic to other hosts is either blocked or passed through without any configuration for
+	// them, so changes to services a sidecar does not import never affect it.
+	var sidecars []config.Config
+	for ns, mode := range map[string]networking.OutboundTrafficPolicy_Mode{
+		"registry-only": networking.OutboundTrafficPolicy_REGISTRY_ONLY,
+		"allow-any":     networking.OutboundTrafficPolicy_ALLOW_ANY,
+	} {
+		sidecars = append(sidecars, config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.Sidecar, Name: "sidecar", Namespace: ns},
+			Spec: &networking.Sidecar{
+				Egress:                []*networking.IstioEgressListener{{Hosts: []string{"ns/*"}}},
+				OutboundTrafficPolicy: &networking.OutboundTrafficPolicy{Mode: mode},
+			},
+		})
+	}
+	env := newTestEnvironment(memory.Make(collections.Pilot))
+	env.ServiceDiscovery = memregistry.NewServiceDiscovery([]*model.Service{
+		{
+			Hostname:   "svc.ns.svc.cluster.local",
+			Ports:      model.PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}},
+			Attributes: model.ServiceAttributes{Namespace: "ns"},
+		},
+		{
+			Hostname:   "svc.other.svc.cluster.local",
+			Ports:      model.PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}},
+			Attributes: model.ServiceAttributes{Namespace: "other"},
+		},
+	})
+	push := newTestPushContext(t, env, nil, sidecars...)
+
+	for _, ns := range []string{"registry-only", "allow-any"} {
+		proxy := &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: ns, Metadata: &model.NodeMetadata{Namespace: ns}}
+		proxy.SetSidecarScope(push)
+		for _, svc := range []struct {
+			namespace string
+			want      bool
+		}{{"ns", true}, {"other", false}} {
+			key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc." + svc.namespace + ".svc.cluster.local", Namespace: svc.namespace}
+			req := &model.PushRequest{
+				Full:           true,
+				Push:           push,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{key: {}},
+			}
+			if got := DefaultProxyNeedsPush(proxy, req); got != svc.want {
+				t.Errorf("%s: DefaultProxyNeedsPush() for %s = %v, want %v", ns, key.Name, got, svc.want)
+			}
+		}
+	}
+}
+
+func TestRegisterSelectorScope(t *testing.T) {
+	defer func(kinds map[config.GroupVersionKind]struct{}) { selectorScopedKinds = kinds }(selectorScopedKinds)
+	selectorScopedKinds = map[config.GroupVersionKind]struct{}{}
+
+	security := []config.GroupVersionKind{gvk.PeerAuthentication, gvk.RequestAuthentication}
+	RegisterSelectorScope(security)
+	for _, kind := range security {
+		if _, f := selectorScopedKinds[kind]; !f {
+			t.Errorf("expected %v to be selector scoped", kind)
+		}
+	}
+	if _, f := selectorScopedKinds[gvk.AuthorizationPolicy]; f {
+		t.Errorf("expected only the registered kinds to be selector scoped")
+	}
+}
+
+func TestRetiredConfigKinds(t *testing.T) {
+	for _, s := range collections.All.All() {
+		if _, f := retiredConfigKinds[s.Resource().GroupVersionKind()]; f {
+			t.Fatalf("kind %v is in the schema, but retired", s.Resource().GroupVersionKind())
+		}
+	}
+
+	logged := 0
+	defer func(log func(model.ConfigKey), limit *rate.Limiter) {
+		logRetiredConfigKind, retiredConfigKindWarnLimit = log, limit
+	}(logRetiredConfigKind, retiredConfigKindWarnLimit)
+	logRetiredConfigKind = func(model.ConfigKey) { logged++ }
+	retiredConfigKindWarnLimit = rate.NewLimiter(rate.Every(time.Hour), 1)
+	counter := func() float64 { return sumValue(t, "pilot_xds_retired_config_kind_updates", "RbacConfig") }
+	before := counter()
+
+	sidecar := &model.Proxy{ID: "sidecar", Type: model.SidecarProxy}
+	gateway := &model.Proxy{ID: "gateway", Type: model.Router}
+	retired := model.ConfigKey{Kind: config.GroupVersionKind{Group: "rbac.istio.io", Version: "v1alpha1", Kind: "RbacConfig"}, Name: "default", Namespace: "ns"}
+	req := &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{retired: {}}}
+	for _, proxy := range []*model.Proxy{sidecar, gateway} {
+		if DefaultProxyNeedsPush(proxy, req) {
+			t.Errorf("%s: expected retired kinds not to push", proxy.ID)
+		}
+		if types := PushTypeFor(proxy, req); len(types) != 0 {
+			t.Errorf("%s: PushTypeFor() = %v, want none", proxy.ID, types)
+		}
+	}
+	want := map[string]PushSkipReason{"sidecar": SkipReasonRetiredKind, "gateway": SkipReasonRetiredKind}
+	if got := PushSkipReport([]*model.Proxy{sidecar, gateway}, req); !reflect.DeepEqual(got, want) {
+		t.Fatalf("PushSkipReport() = %v, want %v", got, want)
+	}
+	if counter() <= before {
+		t.Fatalf("expected the retired config to be counted")
+	}
+	if logged != 1 {
+		t.Fatalf("expected the retired config to be logged once, got %d", logged)
+	}
+
+	// Other configs updated along with them are still pushed.
+	req.ConfigsUpdated[model.ConfigKey{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "ns"}] = struct{}{}
+	if !DefaultProxyNeedsPush(sidecar, req) {
+		t.Fatalf("expected the other configs to push")
+	}
+}
+
+func TestEventHasEndpointImpact(t *testing.T) {
+	cases := []struct {
+		name string
+		req  *model.PushRequest
+		want bool
+	}{
+		{
+			name: "endpoint update",
+			req: &model.PushRequest{
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "foo.com", Namespace: "ns"}: {}},
+			},
+			want: true,
+		},
+		{
+			name: "destination rule",
+			req: &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns"}: {}},
+			},
+			want: true,
+		},
+		{
+			name: "authorization policy",
+			req: &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.AuthorizationPolicy, Name: "policy", Namespace: "ns"}: {}},
+			},
+			want: false,
+		},
+		{
+			name: "all configs",
+			req:  &model.PushRequest{Full: true},
+			want: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventHasEndpointImpact(tt.req); got != tt.want {
+				t.Fatalf("EventHasEndpointImpact() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrainingServicesSkipEds(t *testing.T) {
+	drained := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "drained.com", Namespace: "ns"}
+	sameHost := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "drained.com", Namespace: "other"}
+	live := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "live.com", Namespace: "ns"}
+	request := func(draining []model.ConfigKey, updated ...model.ConfigKey) *model.PushRequest {
+		req := &model.PushRequest{
+			Full:             true,
+			ConfigsUpdated:   map[model.ConfigKey]struct{}{},
+			DrainingServices: map[model.ConfigKey]struct{}{},
+		}
+		for _, key := range updated {
+			req.ConfigsUpdated[key] = struct{}{}
+		}
+		for _, key := range draining {
+			req.DrainingServices[key] = struct{}{}
+		}
+		return req
+	}
+	// builder returns the endpoint builder of a cluster of the hostname, resolved to the service of the
+	// namespace, if any.
+	builder := func(hostname, namespace string) EndpointBuilder {
+		b := EndpointBuilder{hostname: host.Name(hostname)}
+		if namespace != "" {
+			b.service = &model.Service{Hostname: host.Name(hostname), Attributes: model.ServiceAttributes{Namespace: namespace}}
+		}
+		return b
+	}
+	cases := []struct {
+		name        string
+		req         *model.PushRequest
+		cluster     EndpointBuilder
+		wantImpact  bool
+		wantDrained bool
+	}{
+		{"draining only", request([]model.ConfigKey{drained}, drained), builder("drained.com", "ns"), false, true},
+		{"draining and live", request([]model.ConfigKey{drained}, drained, live), builder("live.com", "ns"), true, false},
+		{"host removed", request([]model.ConfigKey{drained}, drained), builder("drained.com", ""), false, true},
+		{"host resolved in other namespace", request([]model.ConfigKey{drained}, drained), builder("drained.com", "other"), false, false},
+		{"host live in other namespace", request([]model.ConfigKey{drained}, drained, sameHost), builder("drained.com", "other"), true, false},
+		{"nothing draining", request(nil, drained), builder("drained.com", "ns"), true, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventHasEndpointImpact(tt.req); got != tt.wantImpact {
+				t.Fatalf("EventHasEndpointImpact() = %v, want %v", got, tt.wantImpact)
+			}
+			if got := drainedCluster(tt.req, tt.cluster); got != tt.wantDrained {
+				t.Fatalf("drainedCluster() = %v, want %v", got, tt.wantDrained)
+			}
+		})
+	}
+}
+
+func TestEnvoyFilterTargetScope(t *testing.T) {
+	sidecar := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	gateway := &model.Proxy{
+		Type:         model.Router,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	cases := []struct {
+		name        string
+		target      model.EnvoyFilterTarget
+		wantSidecar bool
+		wantGateway bool
+	}{
+		{"gateway targeted", model.EnvoyFilterTargetGateways, false, true},
+		{"sidecar targeted", model.EnvoyFilterTargetSidecars, true, false},
+		{"all", model.EnvoyFilterTargetAll, true, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:              true,
+				ConfigsUpdated:    map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "ns"}: {}},
+				EnvoyFilterTarget: tt.target,
+			}
+			if got := DefaultProxyNeedsPush(sidecar, req); got != tt.wantSidecar {
+				t.Errorf("sidecar: DefaultProxyNeedsPush() = %v, want %v", got, tt.wantSidecar)
+			}
+			if got := DefaultProxyNeedsPush(gateway, req); got != tt.wantGateway {
+				t.Errorf("gateway: DefaultProxyNeedsPush() = %v, want %v", got, tt.wantGateway)
+			}
+		})
+	}
+}
+
+func TestDestinationRuleExportToScope(t *testing.T) {
+	proxy := func(proxyType model.NodeType, namespace string) *model.Proxy {
+		return &model.Proxy{
+			Type:            proxyType,
+			ConfigNamespace: namespace,
+			SidecarScope:    &model.SidecarScope{Name: "default", Namespace: namespace, RootNamespace: "istio-system"},
+		}
+	}
+	local := proxy(model.Router, "ns1")
+	specific := proxy(model.Router, "ns2")
+	other := proxy(model.Router, "ns3")
+	cases := []struct {
+		name         string
+		namespaces   map[string]struct{}
+		wantLocal    bool
+		wantSpecific bool
+		wantOther    bool
+	}{
+		{"local", model.DestinationRuleExportedNamespaces(destinationRule("ns1", ".")), true, false, false},
+		{"all", model.DestinationRuleExportedNamespaces(destinationRule("ns1", "*")), true, true, true},
+		{"specific namespace", model.DestinationRuleExportedNamespaces(destinationRule("ns1", "ns2")), false, true, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:                      true,
+				ConfigsUpdated:            map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}: {}},
+				DestinationRuleNamespaces: tt.namespaces,
+			}
+			if got := DefaultProxyNeedsPush(local, req); got != tt.wantLocal {
+				t.Errorf("ns1: DefaultProxyNeedsPush() = %v, want %v", got, tt.wantLocal)
+			}
+			if got := DefaultProxyNeedsPush(specific, req); got != tt.wantSpecific {
+				t.Errorf("ns2: DefaultProxyNeedsPush() = %v, want %v", got, tt.wantSpecific)
+			}
+			if got := DefaultProxyNeedsPush(other, req); got != tt.wantOther {
+				t.Errorf("ns3: DefaultProxyNeedsPush() = %v, want %v", got, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestDestinationRuleHostScope(t *testing.T) {
+	env := newTestEnvironment(memory.Make(collections.Pilot))
+	env.ServiceDiscovery = memregistry.NewServiceDiscovery([]*model.Service{{
+		Hostname:   "svc.ns.svc.cluster.local",
+		Ports:      model.PortList{{Name: "http", Port: 9080, Protocol: protocol.HTTP}},
+		Attributes: model.ServiceAttributes{Namespace: "ns"},
+	}})
+	push := newTestPushContext(t, env, nil)
+	proxy := &model.Proxy{Type: model.SidecarProxy, ConfigNamespace: "ns", Metadata: &model.NodeMetadata{Namespace: "ns"}}
+	proxy.SetSidecarScope(push)
+
+	// No destination rule existed when the scope was computed, so the rule is not among its dependencies.
+	key := model.ConfigKey{Kind: gvk.DestinationRule, Name: "renamed", Namespace: "ns"}
+	cases := []struct {
+		name  string
+		hosts []host.Name
+		want  bool
+	}{
+		{"imported host", []host.Name{"svc.ns.svc.cluster.local"}, true},
+		{"host changed from imported host", []host.Name{"svc.ns.svc.cluster.local", "other.ns.svc.cluster.local"}, true},
+		{"host not imported", []host.Name{"other.ns.svc.cluster.local"}, false},
+		{"unknown hosts", nil, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:                 true,
+				Push:                 push,
+				ConfigsUpdated:       map[model.ConfigKey]struct{}{key: {}},
+				DestinationRuleHosts: map[model.ConfigKey][]host.Name{key: tt.hosts},
+			}
+			if got := DefaultProxyNeedsPush(proxy, req); got != tt.want {
+				t.Fatalf("DefaultProxyNeedsPush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootNamespaceDestinationRuleScope(t *testing.T) {
+	proxies := []*model.Proxy{
+		{Type: model.SidecarProxy, ConfigNamespace: "ns1", SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns1", RootNamespace: "istio-system"}},
+		{Type: model.SidecarProxy, ConfigNamespace: "ns2", SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns2", RootNamespace: "istio-system"}},
+	}
+	cases := []struct {
+		name      string
+		namespace string
+		want      bool
+	}{
+		{"root namespace", "istio-system", true},
+		{"regular namespace", "ns1", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr", Namespace: tt.namespace}: {}},
+			}
+			for _, proxy := range proxies {
+				if got := DefaultProxyNeedsPush(proxy, req); got != tt.want {
+					t.Errorf("%s: DefaultProxyNeedsPush() = %v, want %v", proxy.ConfigNamespace, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestZeroConfigKindFullPushes(t *testing.T) {
+	logged := 0
+	defer func(log func(model.ConfigKey, string), limit *rate.Limiter) {
+		logInvalidConfigKind, invalidConfigKindWarnLimit = log, limit
+	}(logInvalidConfigKind, invalidConfigKindWarnLimit)
+	logInvalidConfigKind = func(model.ConfigKey, string) { logged++ }
+	invalidConfigKindWarnLimit = rate.NewLimiter(rate.Every(time.Hour), 1)
+
+	counter := func() float64 { return sumValue(t, "pilot_xds_invalid_config_kind_updates", "") }
+	before := counter()
+
+	proxy := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	req := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Name: "cfg", Namespace: "ns"}: {}},
+	}
+	for i := 0; i < 2; i++ {
+		if !DefaultProxyNeedsPush(proxy, req) {
+			t.Fatalf("expected a push for an updated config without a kind")
+		}
+		for _, typeURL := range []string{v3.ClusterType, v3.EndpointType, v3.ListenerType} {
+			if !PushTypeFor(proxy, req)[typeURL] {
+				t.Fatalf("expected %v to be pushed for an updated config without a kind", typeURL)
+			}
+		}
+	}
+
+	if got := counter() - before; got < 2 {
+		t.Errorf("expected the counter to increase for every check, got %v", got)
+	}
+	if logged != 1 {
+		t.Errorf("expected the warning to be logged once, got %d", logged)
+	}
+}
+
+func TestConfigAffectsProxyOrderIndependent(t *testing.T) {
+	unknown := config.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Unknown"}
+	sidecar := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	router := &model.Proxy{
+		Type:         model.Router,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	keys := []model.ConfigKey{
+		{Kind: gvk.Gateway, Name: "gw", Namespace: "ns"},
+		{Kind: unknown, Name: "cfg", Namespace: "ns"},
+	}
+
+	// Map iteration order is randomized, so every run may see the configs in a different order.
+	for _, proxy := range []*model.Proxy{sidecar, router} {
+		for i := 0; i < 100; i++ {
+			req := &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{}}
+			for j := range keys {
+				req.ConfigsUpdated[keys[(i+j)%len(keys)]] = struct{}{}
+			}
+			if !DefaultProxyNeedsPush(proxy, req) {
+				t.Fatalf("expected %v to be pushed for an update of a gateway and an unknown kind", proxy.Type)
+			}
+		}
+	}
+
+	defer func(log func(model.ConfigKey, string)) { logInvalidConfigKind = log }(logInvalidConfigKind)
+	logInvalidConfigKind = func(model.ConfigKey, string) {}
+	counter := func() float64 { return sumValue(t, "pilot_xds_invalid_config_kind_updates", "") }
+	before := counter()
+	req := &model.PushRequest{
+		Full: true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.Gateway, Name: "gw", Namespace: "ns"}: {},
+			{Name: "cfg", Namespace: "ns"}:                   {},
+		},
+	}
+	for i := 0; i < 100; i++ {
+		if !ConfigAffectsProxy(req, router) {
+			t.Fatalf("expected the router to be affected by an update of a gateway and a config without a kind")
+		}
+	}
+	if got := counter() - before; got != 100 {
+		t.Errorf("expected the config without a kind to be counted for every check, got %v", got)
+	}
+}
+
+func TestNilMetadataClassification(t *testing.T) {
+	defer SetClassificationProfile(activeClassificationProfile())
+	SetClassificationProfile(ClassificationAggressive)
+
+	push := newTestPushContext(t, newTestEnvironment(memory.Make(collections.Pilot)), nil)
+	proxies := []*model.Proxy{
+		{ID: "sidecar", Type: model.SidecarProxy},
+		{ID: "router", Type: model.Router},
+	}
+	svc := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns", Namespace: "ns"}
+	cases := []struct {
+		name string
+		req  *model.PushRequest
+	}{
+		{"cluster IDs", &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{svc: {}},
+			ClusterIDs:     map[string]struct{}{"cluster1": {}},
+		}},
+		{"namespace selectors", &model.PushRequest{
+			Full:               true,
+			ConfigsUpdated:     map[model.ConfigKey]struct{}{svc: {}},
+			NamespaceSelectors: labels.Collection{{"env": "prod"}},
+		}},
+		{"peer authentication", &model.PushRequest{
+			Full:           true,
+			Push:           push,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.PeerAuthentication, Name: "pa", Namespace: "ns"}: {}},
+		}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing is known about proxies without metadata, so they are pushed.
+			for _, proxy := range proxies {
+				if !DefaultProxyNeedsPush(proxy, tt.req) {
+					t.Errorf("expected %v without metadata to be pushed", proxy.ID)
+				}
+				if len(PushTypeFor(proxy, tt.req)) == 0 {
+					t.Errorf("expected types to be pushed to %v without metadata", proxy.ID)
+				}
+				if code := PushDecisionCode(proxy, tt.req); code == DecisionSkipped {
+					t.Errorf("expected a push decision for %v without metadata, got %v", proxy.ID, code)
+				}
+			}
+			if report := PushSkipReport(proxies, tt.req); len(report) != 0 {
+				t.Errorf("expected no proxies without metadata to be skipped, got %v", report)
+			}
+		})
+	}
+}
+
+func TestPartitionProxiesForPushZeroTargets(t *testing.T) {
+	counter := func() float64 { return sumValue(t, "pilot_xds_zero_target_pushes", gvk.ServiceEntry.Kind) }
+
+	var proxies []*model.Proxy
+	for _, ns := range []string{"ns1", "ns2"} {
+		proxy := &model.Proxy{
+			Type:         model.SidecarProxy,
+			SidecarScope: &model.SidecarScope{Name: "default", Namespace: ns, RootNamespace: "istio-system"},
+		}
+		proxy.SidecarScope.AddConfigDependencies(model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc." + ns, Namespace: ns})
+		proxies = append(proxies, proxy)
+	}
+
+	// A ServiceEntry exported only to its own namespace, which has no proxies.
+	private := &model.PushRequest{
+		Full: true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "private.ns3", Namespace: "ns3"}: {},
+		},
+	}
+	before := counter()
+	push, skip := PartitionProxiesForPush(private, proxies, DefaultProxyNeedsPush)
+	if len(push) != 0 || len(skip) != len(proxies) {
+		t.Fatalf("expected all proxies to be skipped, got push %v skip %v", push, skip)
+	}
+	if got := counter() - before; got != 1 {
+		t.Errorf("expected the zero-target push to be counted once, got %v", got)
+	}
+
+	visible := &model.PushRequest{
+		Full: true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "svc.ns1", Namespace: "ns1"}: {},
+		},
+	}
+	before = counter()
+	push, skip = PartitionProxiesForPush(visible, proxies, DefaultProxyNeedsPush)
+	if len(push) != 1 || push[0] != proxies[0] || len(skip) != 1 {
+		t.Fatalf("expected only the proxy in ns1 to be pushed, got push %v skip %v", push, skip)
+	}
+	if got := counter() - before; got != 0 {
+		t.Errorf("expected a push reaching proxies not to be counted, got %v", got)
+	}
+}
+
+func TestPartitionOwnedProxiesForPush(t *testing.T) {
+	var proxies []*model.Proxy
+	for _, id := range []string{"a.ns1", "b.ns1", "c.ns1", "d.ns1"} {
+		proxy := &model.Proxy{
+			ID:           id,
+			Type:         model.SidecarProxy,
+			SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns1", RootNamespace: "istio-system"},
+		}
+		proxy.SidecarScope.AddConfigDependencies(model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns1", Namespace: "ns1"})
+		proxies = append(proxies, proxy)
+	}
+	// This instance owns a and c; b and d are connected to another instance.
+	owned := func(proxy *model.Proxy) bool {
+		return proxy.ID == "a.ns1" || proxy.ID == "c.ns1"
+	}
+
+	req := &model.PushRequest{
+		Full: true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "svc.ns1", Namespace: "ns1"}: {},
+		},
+	}
+	var evaluated []string
+	needsPush := func(proxy *model.Proxy, req *model.PushRequest) bool {
+		evaluated = append(evaluated, proxy.ID)
+		return DefaultProxyNeedsPush(proxy, req)
+	}
+	push, skip := PartitionOwnedProxiesForPush(req, proxies, needsPush, owned)
+	if len(push) != 2 || push[0] != proxies[0] || push[1] != proxies[2] || len(skip) != 0 {
+		t.Fatalf("expected only the owned proxies to be pushed, got push %v skip %v", push, skip)
+	}
+	if !reflect.DeepEqual(evaluated, []string{"a.ns1", "c.ns1"}) {
+		t.Errorf("expected only the owned proxies to be evaluated, got %v", evaluated)
+	}
+
+	push, skip = PartitionOwnedProxiesForPush(req, proxies, DefaultProxyNeedsPush, nil)
+	if len(push) != len(proxies) || len(skip) != 0 {
+		t.Errorf("expected all proxies to be pushed without an ownership filter, got push %v skip %v", push, skip)
+	}
+}
+
+func TestSamePushScope(t *testing.T) {
+	var proxies []*model.Proxy
+	for _, ns := range []string{"ns1", "ns2"} {
+		proxy := &model.Proxy{
+			Type:         model.SidecarProxy,
+			SidecarScope: &model.SidecarScope{Name: "default", Namespace: ns, RootNamespace: "istio-system"},
+		}
+		proxy.SidecarScope.AddConfigDependencies(
+			model.ConfigKey{Kind: gvk.ServiceEntry, Name: "a." + ns, Namespace: ns},
+			model.ConfigKey{Kind: gvk.ServiceEntry, Name: "b." + ns, Namespace: ns},
+		)
+		proxies = append(proxies, proxy)
+	}
+	updated := func(keys ...model.ConfigKey) *model.PushRequest {
+		req := &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{}}
+		for _, key := range keys {
+			req.ConfigsUpdated[key] = struct{}{}
+		}
+		return req
+	}
+	a1 := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "a.ns1", Namespace: "ns1"}
+	b1 := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "b.ns1", Namespace: "ns1"}
+	a2 := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "a.ns2", Namespace: "ns2"}
+
+	cases := []struct {
+		name string
+		a, b *model.PushRequest
+		want bool
+	}{
+		{"same proxies", updated(a1), updated(b1), true},
+		{"other proxies", updated(a1), updated(a2), false},
+		{"more proxies", updated(a1), updated(a1, a2), false},
+		{"all proxies", updated(), updated(a1, a2), true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SamePushScope(tt.a, tt.b, proxies); got != tt.want {
+				t.Errorf("SamePushScope() = %v, want %v", got, tt.want)
+			}
+			if got := SamePushScope(tt.b, tt.a, proxies); got != tt.want {
+				t.Errorf("SamePushScope() of swapped requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDominantKind(t *testing.T) {
+	cases := []struct {
+		name    string
+		configs map[model.ConfigKey]struct{}
+		want    string
+	}{
+		{"no configs", nil, "unknown"},
+		{"most configs", map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "a", Namespace: "ns"}:    {},
+			{Kind: gvk.ServiceEntry, Name: "b", Namespace: "ns"}:    {},
+			{Kind: gvk.DestinationRule, Name: "a", Namespace: "ns"}: {},
+		}, gvk.ServiceEntry.Kind},
+		{"tie", map[model.ConfigKey]struct{}{
+			{Kind: gvk.VirtualService, Name: "a", Namespace: "ns"}:  {},
+			{Kind: gvk.DestinationRule, Name: "a", Namespace: "ns"}: {},
+		}, gvk.DestinationRule.Kind},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dominantKind(&model.PushRequest{ConfigsUpdated: tt.configs}); got != tt.want {
+				t.Fatalf("dominantKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointDeltaPassedThrough(t *testing.T) {
+	s := NewDiscoveryServer(&model.Environment{}, nil, "")
+	s.CachesSynced()
+	key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns.svc.cluster.local", Namespace: "ns"}
+	e1 := &model.IstioEndpoint{Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http", ServiceAccount: "sa"}
+	e2 := &model.IstioEndpoint{Address: "10.0.0.2", EndpointPort: 8080, ServicePortName: "http", ServiceAccount: "sa"}
+	update := func(endpoints ...*model.IstioEndpoint) *model.PushRequest {
+		s.EDSUpdate("cluster1", key.Name, key.Namespace, endpoints)
+		return <-s.pushChannel
+	}
+
+	// The first endpoints of a service require a full push, which recomputes all endpoints.
+	if req := update(e1); !req.Full {
+		t.Fatalf("expected a full push for a new service")
+	} else if _, f := EndpointDeltaFor(req, key); f {
+		t.Fatalf("expected no endpoint delta for a full push")
+	}
+
+	cases := []struct {
+		name      string
+		endpoints []*model.IstioEndpoint
+		want      *model.EndpointDelta
+		impact    bool
+	}{
+		{"endpoint added", []*model.IstioEndpoint{e1, e2}, &model.EndpointDelta{ClusterID: "cluster1", Added: []*model.IstioEndpoint{e2}}, true},
+		{"endpoint removed", []*model.IstioEndpoint{e2}, &model.EndpointDelta{ClusterID: "cluster1", Removed: []*model.IstioEndpoint{e1}}, true},
+		{"endpoints unchanged", []*model.IstioEndpoint{e2}, &model.EndpointDelta{ClusterID: "cluster1"}, false},
+		{"all endpoints removed", nil, &model.EndpointDelta{ClusterID: "cluster1", Removed: []*model.IstioEndpoint{e2}}, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := update(tt.endpoints...)
+			got, f := EndpointDeltaFor(req, key)
+			if !f {
+				t.Fatalf("expected an endpoint delta for %v", req)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("EndpointDeltaFor() = %+v, want %+v", got, tt.want)
+			}
+			if got := EventHasEndpointImpact(req); got != tt.impact {
+				t.Fatalf("EventHasEndpointImpact() = %v, want %v", got, tt.impact)
+			}
+		})
+	}
+}
+
+func TestConfigUpdateCorrelationID(t *testing.T) {
+	s := NewDiscoveryServer(&model.Environment{}, nil, "")
+	s.ConfigUpdate(&model.PushRequest{Full: true})
+	first := <-s.pushChannel
+	s.ConfigUpdate(&model.PushRequest{Full: true})
+	second := <-s.pushChannel
+	if first.CorrelationID == "" || first.CorrelationID == second.CorrelationID {
+		t.Fatalf("expected distinct correlation ids, got %q and %q", first.CorrelationID, second.CorrelationID)
+	}
+
+	s.ConfigUpdate((&model.PushRequest{Full: true}).SetCorrelationID("producer"))
+	if got := (<-s.pushChannel).CorrelationID; got != "producer" {
+		t.Fatalf("expected the correlation id of the producer to be kept, got %q", got)
+	}
+}
+
+func TestDeletedServiceDraining(t *testing.T) {
+	s := NewDiscoveryServer(&model.Environment{}, nil, "")
+	s.CachesSynced()
+	key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns.svc.cluster.local", Namespace: "ns"}
+	endpoint := &model.IstioEndpoint{Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http"}
+	push := func() *model.PushRequest {
+		s.ConfigUpdate(&model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{key: {}}})
+		return <-s.pushChannel
+	}
+	draining := func(req *model.PushRequest) bool {
+		_, f := req.DrainingServices[key]
+		return f
+	}
+	for _, cluster := range []string{"cluster1", "cluster2"} {
+		s.EDSUpdate(cluster, key.Name, key.Namespace, []*model.IstioEndpoint{endpoint})
+		<-s.pushChannel
+	}
+
+	// The service is still known to another cluster.
+	s.SvcUpdate("cluster1", key.Name, key.Namespace, model.EventDelete)
+	if req := push(); draining(req) {
+		t.Fatalf("expected a service with remaining shards not to be draining")
+	}
+
+	s.SvcUpdate("cluster2", key.Name, key.Namespace, model.EventDelete)
+	if req := push(); !draining(req) {
+		t.Fatalf("expected a service without shards to be draining")
+	}
+	if req := push(); draining(req) {
+		t.Fatalf("expected the deletion to only be flagged once")
+	}
+
+	// A service recreated before its update is pushed is not draining.
+	s.EDSUpdate("cluster1", key.Name, key.Namespace, []*model.IstioEndpoint{endpoint})
+	<-s.pushChannel
+	s.SvcUpdate("cluster1", key.Name, key.Namespace, model.EventDelete)
+	s.EDSUpdate("cluster1", key.Name, key.Namespace, []*model.IstioEndpoint{endpoint})
+	if req := <-s.pushChannel; draining(req) {
+		t.Fatalf("expected a recreated service not to be draining")
+	}
+}
+
+func TestEndpointDeltaChangedEndpoint(t *testing.T) {
+	old := &model.IstioEndpoint{Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http", Labels: map[string]string{"v": "1"}}
+	updated := &model.IstioEndpoint{Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http", Labels: map[string]string{"v": "2"}}
+	want := &model.EndpointDelta{Added: []*model.IstioEndpoint{updated}, Removed: []*model.IstioEndpoint{old}}
+	if got := endpointDelta("", []*model.IstioEndpoint{old}, []*model.IstioEndpoint{updated}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpointDelta() = %+v, want %+v", got, want)
+	}
+	want = &model.EndpointDelta{}
+	if got := endpointDelta("", []*model.IstioEndpoint{old}, []*model.IstioEndpoint{old}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpointDelta() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEndpointChangePushTypes(t *testing.T) {
+	s := NewDiscoveryServer(&model.Environment{}, nil, "")
+	s.CachesSynced()
+	key := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns.svc.cluster.local", Namespace: "ns"}
+	endpoint := func(address, version string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{
+			Address: address, EndpointPort: 8080, ServicePortName: "http", ServiceAccount: "sa",
+			Labels: map[string]string{"version": version},
+		}
+	}
+	update := func(endpoints ...*model.IstioEndpoint) *model.PushRequest {
+		s.EDSUpdate("cluster1", key.Name, key.Namespace, endpoints)
+		return <-s.pushChannel
+	}
+	update(endpoint("10.0.0.1", "v1"))
+
+	sidecar := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	sidecar.SidecarScope.AddConfigDependencies(key)
+	cases := []struct {
+		name     string
+		endpoint *model.IstioEndpoint
+	}{
+		// Subset clusters are defined by destination rules, not by the endpoints, so an endpoint moving to
+		// another subset only changes the endpoints of the subset clusters.
+		{"label change", endpoint("10.0.0.1", "v2")},
+		{"address change", endpoint("10.0.0.2", "v2")},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := update(tt.endpoint)
+			if req.Full {
+				t.Fatalf("expected an incremental push, got a full push")
+			}
+			want := map[string]bool{v3.EndpointType: true}
+			if got := PushTypeFor(sidecar, req); !reflect.DeepEqual(got, want) {
+				t.Fatalf("PushTypeFor() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEndpointUpdateClusterIDs(t *testing.T) {
+	env := newTestEnvironment(memory.Make(collections.Pilot))
+	env.PushContext = model.NewPushContext()
+	if err := env.PushContext.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s := NewDiscoveryServer(env, nil, "")
+	s.CachesSynced()
+	endpoint := &model.IstioEndpoint{Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http", ServiceAccount: "sa"}
+	update := func(cluster, hostname, namespace string, endpoints ...*model.IstioEndpoint) *model.PushRequest {
+		s.EDSUpdate(cluster, hostname, namespace, endpoints)
+		return <-s.pushChannel
+	}
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{ClusterID: "Kubernetes"}}
+
+	cases := []struct {
+		name      string
+		cluster   string
+		hostname  string
+		namespace string
+		want      map[string]struct{}
+		pushed    bool
+	}{
+		{"cluster-local service", "Kubernetes", "dns.kube-system.svc.cluster.local", "kube-system", map[string]struct{}{"Kubernetes": {}}, true},
+		{"cluster-local service of another cluster", "remote", "ns.kube-system.svc.cluster.local", "kube-system", map[string]struct{}{"remote": {}}, false},
+		{"global service", "Kubernetes", "svc.ns.svc.cluster.local", "ns", nil, true},
+		// The ServiceEntry registry has no cluster, its endpoints apply to proxies in all clusters.
+		{"cluster-local service entry", "", "se.kube-system.svc.cluster.local", "kube-system", nil, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			// The first endpoints of a service require a full push, which may change clusters in any cluster.
+			if req := update(tt.cluster, tt.hostname, tt.namespace, endpoint); !req.Full || req.ClusterIDs != nil {
+				t.Fatalf("expected a full push of all clusters, got %+v", req)
+			}
+			req := update(tt.cluster, tt.hostname, tt.namespace)
+			if req.Full {
+				t.Fatalf("expected an incremental push, got a full push")
+			}
+			if !reflect.DeepEqual(req.ClusterIDs, tt.want) {
+				t.Fatalf("ClusterIDs = %v, want %v", req.ClusterIDs, tt.want)
+			}
+			if got := clusterIDsIncludeProxy(req.ClusterIDs, proxy); got != tt.pushed {
+				t.Fatalf("clusterIDsIncludeProxy() = %v, want %v", got, tt.pushed)
+			}
+		})
+	}
+}
+
+func TestPushSkipReport(t *testing.T) {
+	env := newTestEnvironment(memory.Make(collections.Pilot))
+	old := newTestPushContext(t, env, nil)
+	push := newTestPushContext(t, env, old,
+		config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.PeerAuthentication, Name: "workload", Namespace: "ns"},
+			Spec: &securityBeta.PeerAuthentication{
+				Selector: &selectorpb.WorkloadSelector{MatchLabels: map[string]string{"app": "foo"}},
+				Mtls:     &securityBeta.PeerAuthentication_MutualTLS{Mode: securityBeta.PeerAuthentication_MutualTLS_STRICT},
+			},
+		},
+		config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.RequestAuthentication, Name: "workload", Namespace: "ns"},
+			Spec: &securityBeta.RequestAuthentication{
+				Selector: &selectorpb.WorkloadSelector{MatchLabels: map[string]string{"app": "foo"}},
+			},
+		},
+	)
+
+	sidecar := &model.Proxy{
+		ID:              "sidecar",
+		Type:            model.SidecarProxy,
+		ConfigNamespace: "ns",
+		Metadata:        &model.NodeMetadata{Namespace: "ns", ClusterID: "cluster1", Labels: map[string]string{"app": "bar"}},
+		NamespaceLabels: map[string]string{"istio-injection": "disabled"},
+		SidecarScope:    &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	sidecar.SidecarScope.AddConfigDependencies(model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns", Namespace: "ns"})
+	gateway := &model.Proxy{ID: "gateway", Type: model.Router, Metadata: &model.NodeMetadata{ClusterID: "cluster1"}}
+
+	configs := func(keys ...model.ConfigKey) map[model.ConfigKey]struct{} {
+		updated := map[model.ConfigKey]struct{}{}
+		for _, key := range keys {
+			updated[key] = struct{}{}
+		}
+		return updated
+	}
+	gw := model.ConfigKey{Kind: gvk.Gateway, Name: "gw", Namespace: "ns"}
+	otherSvc := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.other", Namespace: "other"}
+	cases := []struct {
+		name string
+		req  *model.PushRequest
+		want map[string]PushSkipReason
+	}{
+		{
+			"other cluster",
+			&model.PushRequest{ConfigsUpdated: configs(gw), ClusterIDs: map[string]struct{}{"cluster2": {}}},
+			map[string]PushSkipReason{"sidecar": SkipReasonCluster, "gateway": SkipReasonCluster},
+		},
+		{
+			"other namespace labels",
+			&model.PushRequest{ConfigsUpdated: configs(gw), NamespaceSelectors: labels.Collection{{"istio-injection": "enabled"}}},
+			map[string]PushSkipReason{"sidecar": SkipReasonNamespaceSelector},
+		},
+		{
+			"gateway only config",
+			&model.PushRequest{ConfigsUpdated: configs(gw)},
+			map[string]PushSkipReason{"sidecar": SkipReasonProxyType},
+		},
+		{
+			"envoy filter for gateways",
+			&model.PushRequest{
+				ConfigsUpdated:    configs(model.ConfigKey{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "ns"}),
+				EnvoyFilterTarget: model.EnvoyFilterTargetGateways,
+			},
+			map[string]PushSkipReason{"sidecar": SkipReasonEnvoyFilterTarget},
+		},
+		{
+			"destination rule not exported",
+			&model.PushRequest{
+				ConfigsUpdated:            configs(model.ConfigKey{Kind: gvk.DestinationRule, Name: "dr", Namespace: "other"}),
+				DestinationRuleNamespaces: map[string]struct{}{"other": {}},
+			},
+			map[string]PushSkipReason{"sidecar": SkipReasonNotExported, "gateway": SkipReasonNotExported},
+		},
+		{
+			"service port not imported",
+			&model.PushRequest{
+				ConfigsUpdated: configs(model.ConfigKey{Kind: gvk.ServiceEntry, Name: "svc.ns", Namespace: "ns"}),
+				ServicePorts:   map[model.ConfigKey][]int{{Kind: gvk.ServiceEntry, Name: "svc.ns", Namespace: "ns"}: {8080}},
+			},
+			map[string]PushSkipReason{"sidecar": SkipReasonPortNotImported},
+		},
+		{
+			"workload not selected",
+			&model.PushRequest{Push: push, ConfigsUpdated: configs(model.ConfigKey{Kind: gvk.PeerAuthentication, Name: "workload", Namespace: "ns"})},
+			map[string]PushSkipReason{"sidecar": SkipReasonNotSelected},
+		},
+		{
+			"request authentication workload not selected",
+			&model.PushRequest{Push: push, ConfigsUpdated: configs(model.ConfigKey{Kind: gvk.RequestAuthentication, Name: "workload", Namespace: "ns"})},
+			map[string]PushSkipReason{"sidecar": SkipReasonNotSelected},
+		},
+		{
+			"service not imported",
+			&model.PushRequest{ConfigsUpdated: configs(otherSvc)},
+			map[string]PushSkipReason{"sidecar": SkipReasonNoDependency},
+		},
+		{
+			"mixed",
+			&model.PushRequest{ConfigsUpdated: configs(gw, otherSvc)},
+			map[string]PushSkipReason{"sidecar": SkipReasonMixed},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PushSkipReport([]*model.Proxy{sidecar, gateway}, tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("PushSkipReport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushDecisionCode(t *testing.T) {
+	scope := &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"}
+	sidecar := &model.Proxy{Type: model.SidecarProxy, SidecarScope: scope}
+	withService := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: scope,
+		ServiceInstances: []*model.ServiceInstance{{
+			Service: &model.Service{Hostname: "own.ns.svc.cluster.local", Attributes: model.ServiceAttributes{Namespace: "ns"}},
+		}},
+	}
+	unscoped := &model.Proxy{Type: model.SidecarProxy}
+	gateway := &model.Proxy{Type: model.Router}
+	updated := func(kind config.GroupVersionKind, name, namespace string) *model.PushRequest {
+		return &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: kind, Name: name, Namespace: namespace}: {}},
+		}
+	}
+	cases := []struct {
+		name  string
+		proxy *model.Proxy
+		req   *model.PushRequest
+		want  DecisionCode
+	}{
+		{"no configs updated", sidecar, &model.PushRequest{Full: true}, DecisionFullPush},
+		{"gateway", gateway, updated(gvk.Gateway, "gw", "ns"), DecisionGatewayMatch},
+		{"gateway kind for sidecar", sidecar, updated(gvk.Gateway, "gw", "ns"), DecisionSkipped},
+		{"sidecar in namespace", sidecar, updated(gvk.Sidecar, "sidecar", "ns"), DecisionNamespaceMatch},
+		{"sidecar in other namespace", sidecar, updated(gvk.Sidecar, "sidecar", "other"), DecisionSkipped},
+		{"imported service", unscoped, updated(gvk.ServiceEntry, "svc.ns.svc.cluster.local", "ns"), DecisionServiceDep},
+		{"service not imported", sidecar, updated(gvk.ServiceEntry, "svc.ns.svc.cluster.local", "ns"), DecisionSkipped},
+		{"own service", withService, updated(gvk.ServiceEntry, "own.ns.svc.cluster.local", "ns"), DecisionServiceDep},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PushDecisionCode(tt.proxy, tt.req); got != tt.want {
+				t.Fatalf("PushDecisionCode() = %v, want %v", got, tt.want)
+			}
+			if pushed := PushDecisionCode(tt.proxy, tt.req) != DecisionSkipped; pushed != DefaultProxyNeedsPush(tt.proxy, tt.req) {
+				t.Fatalf("PushDecisionCode() disagrees with DefaultProxyNeedsPush()")
+			}
+		})
+	}
+}
+
+func TestBuildPushDecisionRecords(t *testing.T) {
+	sidecar := func(id, namespace string) *model.Proxy {
+		return &model.Proxy{
+			ID:              id,
+			Type:            model.SidecarProxy,
+			ConfigNamespace: namespace,
+			SidecarScope:    &model.SidecarScope{Name: "default", Namespace: namespace, RootNamespace: "istio-system"},
+		}
+	}
+	local := sidecar("local", "ns1")
+	remote := sidecar("remote", "ns2")
+	gateway := &model.Proxy{ID: "gateway", Type: model.Router}
+	proxies := []*model.Proxy{local, remote, gateway}
+	sortedTypes := func(types map[string]bool) []string {
+		out := make([]string, 0, len(types))
+		for typeURL := range types {
+			out = append(out, typeURL)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	t.Run("gateway change", func(t *testing.T) {
+		req := &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.Gateway, Name: "gw", Namespace: "ns1"}: {}},
+		}
+		fingerprint := req.Fingerprint()
+		want := []PushDecisionRecord{
+			{ProxyID: "local", Fingerprint: fingerprint, Decision: "skipped", SkipReason: SkipReasonProxyType},
+			{ProxyID: "remote", Fingerprint: fingerprint, Decision: "skipped", SkipReason: SkipReasonProxyType},
+			{
+				ProxyID:     "gateway",
+				Fingerprint: fingerprint,
+				NeedsPush:   true,
+				Types:       []string{v3.ClusterType, v3.ListenerType, v3.RouteType},
+				Decision:    "gateway match",
+			},
+		}
+		if got := BuildPushDecisionRecords(proxies, req); !reflect.DeepEqual(got, want) {
+			t.Fatalf("BuildPushDecisionRecords() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("scoped sidecar change", func(t *testing.T) {
+		req := &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.Sidecar, Name: "sidecar", Namespace: "ns1"}: {}},
+		}
+		fingerprint := req.Fingerprint()
+		want := []PushDecisionRecord{
+			{
+				ProxyID:     "local",
+				Fingerprint: fingerprint,
+				NeedsPush:   true,
+				Types:       sortedTypes(PushTypeFor(local, req)),
+				Decision:    "namespace match",
+			},
+			{ProxyID: "remote", Fingerprint: fingerprint, Decision: "skipped", SkipReason: SkipReasonNoDependency},
+			{ProxyID: "gateway", Fingerprint: fingerprint, Decision: "skipped", SkipReason: SkipReasonProxyType},
+		}
+		if got := BuildPushDecisionRecords(proxies, req); !reflect.DeepEqual(got, want) {
+			t.Fatalf("BuildPushDecisionRecords() = %+v, want %+v", got, want)
+		}
+		if len(want[0].Types) == 0 {
+			t.Fatalf("expected types to be pushed to the sidecar in the namespace of the change")
+		}
+	})
+}
+
+func TestStatsOnlyChangePushTypes(t *testing.T) {
+	sidecar := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	ef := model.ConfigKey{Kind: gvk.EnvoyFilter, Name: "stats", Namespace: "ns"}
+	req := &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{ef: {}}, StatsOnly: true}
+	if got, want := PushTypeFor(sidecar, req), map[string]bool{v3.ListenerType: true}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("PushTypeFor() = %v, want %v", got, want)
+	}
+	if EventHasEndpointImpact(req) {
+		t.Fatalf("expected a stats only change to have no endpoint impact")
+	}
+
+	// Merged with another change, the other types are pushed again.
+	merged := req.Merge(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "cluster", Namespace: "ns"}: {}},
+	})
+	if got := PushTypeFor(sidecar, merged); !got[v3.ClusterType] || !got[v3.EndpointType] || !got[v3.ListenerType] {
+		t.Fatalf("expected clusters, endpoints and listeners to be pushed, got %v", got)
+	}
+}
+
+func TestDestinationRuleChangePushTypes(t *testing.T) {
+	rule := func(policy networking.LoadBalancerSettings_SimpleLB, subsets ...string) config.Config {
+		dr := &networking.DestinationRule{
+			Host: "svc.ns.svc.cluster.local",
+			TrafficPolicy: &networking.TrafficPolicy{LoadBalancer: &networking.LoadBalancerSettings{
+				LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: policy},
+			}},
+		}
+		for _, name := range subsets {
+			dr.Subsets = append(dr.Subsets, &networking.Subset{Name: name, Labels: map[string]string{"version": name}})
+		}
+		return config.Config{Meta: config.Meta{GroupVersionKind: gvk.DestinationRule, Name: "dr", Namespace: "ns"}, Spec: dr}
+	}
+	gateway := &model.Proxy{Type: model.Router}
+	cases := []struct {
+		name string
+		old  config.Config
+		curr config.Config
+		want map[string]bool
+	}{
+		{
+			"subset added",
+			rule(networking.LoadBalancerSettings_ROUND_ROBIN, "v1"),
+			rule(networking.LoadBalancerSettings_ROUND_ROBIN, "v1", "v2"),
+			map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.RouteType: true},
+		},
+		{
+			"traffic policy only",
+			rule(networking.LoadBalancerSettings_ROUND_ROBIN, "v1"),
+			rule(networking.LoadBalancerSettings_LEAST_CONN, "v1"),
+			map[string]bool{v3.ClusterType: true, v3.EndpointType: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:                  true,
+				ConfigsUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns"}: {}},
+				DestinationRuleChange: model.ClassifyDestinationRuleChange(tt.old, tt.curr),
+			}
+			if got := PushTypeFor(gateway, req); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("PushTypeFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVirtualServiceChangePushTypes(t *testing.T) {
+	vs := func(route *networking.HTTPRoute) config.Config {
+		route.Route = []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "svc.ns.svc.cluster.local"}}}
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "vs", Namespace: "ns"},
+			Spec: &networking.VirtualService{Hosts: []string{"svc.ns.svc.cluster.local"}, Http: []*networking.HTTPRoute{route}},
+		}
+	}
+	sidecar := &model.Proxy{Type: model.SidecarProxy}
+	cases := []struct {
+		name string
+		old  config.Config
+		curr config.Config
+		want map[string]bool
+	}{
+		{
+			"header only",
+			vs(&networking.HTTPRoute{}),
+			vs(&networking.HTTPRoute{Headers: &networking.Headers{
+				Response: &networking.Headers_HeaderOperations{Add: map[string]string{"x-foo": "bar"}},
+			}}),
+			map[string]bool{v3.ClusterType: true, v3.RouteType: true},
+		},
+		{
+			"new match",
+			vs(&networking.HTTPRoute{}),
+			vs(&networking.HTTPRoute{Match: []*networking.HTTPMatchRequest{{Port: 8080}}}),
+			map[string]bool{v3.ClusterType: true, v3.ListenerType: true, v3.RouteType: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:                 true,
+				ConfigsUpdated:       map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns"}: {}},
+				VirtualServiceChange: model.ClassifyVirtualServiceChange(tt.old, tt.curr),
+			}
+			if got := PushTypeFor(sidecar, req); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("PushTypeFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassificationProfile(t *testing.T) {
+	defer SetClassificationProfile(ClassificationAggressive)
+
+	gateway := &model.Proxy{Type: model.Router}
+	sidecar := &model.Proxy{
+		Type:         model.SidecarProxy,
+		SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+	}
+	tlsChange := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.Gateway, Name: "gw", Namespace: "ns"}: {}},
+		GatewayChange:  model.GatewayChangeTLS,
+	}
+	gatewayFilter := &model.PushRequest{
+		Full:              true,
+		ConfigsUpdated:    map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "istio-system"}: {}},
+		EnvoyFilterTarget: model.EnvoyFilterTargetGateways,
+	}
+
+	SetClassificationProfile(ClassificationAggressive)
+	aggressive := PushTypeFor(gateway, tlsChange)
+	if DefaultProxyNeedsPush(sidecar, gatewayFilter) {
+		t.Fatalf("expected the aggressive profile to skip sidecars for gateway envoy filters")
+	}
+
+	SetClassificationProfile(ClassificationConservative)
+	conservative := PushTypeFor(gateway, tlsChange)
+	if len(conservative) <= len(aggressive) {
+		t.Fatalf("expected the conservative profile to push more types, got %v, aggressive %v", conservative, aggressive)
+	}
+	for typeURL := range aggressive {
+		if !conservative[typeURL] {
+			t.Errorf("expected the conservative profile to push %v", typeURL)
+		}
+	}
+	if !DefaultProxyNeedsPush(sidecar, gatewayFilter) {
+		t.Fatalf("expected the conservative profile to push sidecars for gateway envoy filters")
+	}
+	if tlsChange.GatewayChange != model.GatewayChangeTLS || gatewayFilter.EnvoyFilterTarget != model.EnvoyFilterTargetGateways {
+		t.Fatalf("expected the push requests not to be modified")
+	}
+}
+
+func TestInitialSyncBypassesScoping(t *testing.T) {
+	s := NewDiscoveryServer(&model.Environment{}, nil, "")
+	update := func() *model.PushRequest {
+		s.ConfigUpdate(&model.PushRequest{
+			Full:              true,
+			ConfigsUpdated:    map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "istio-system"}: {}},
+			EnvoyFilterTarget: model.EnvoyFilterTargetGateways,
+		})
+		return <-s.pushChannel
+	}
+
+	if req := update(); !req.Full || len(req.ConfigsUpdated) != 0 || req.EnvoyFilterTarget != model.EnvoyFilterTargetAll {
+		t.Fatalf("expected a full push of all configs before the caches are synced, got %v", req)
+	}
+
+	s.CachesSynced()
+	if req := update(); len(req.ConfigsUpdated) != 1 || req.EnvoyFilterTarget != model.EnvoyFilterTargetGateways {
+		t.Fatalf("expected the push request to be kept after the caches are synced, got %v", req)
+	}
+}
+
+func TestSkippedPushesCatchUp(t *testing.T) {
+	// A ProxyNeedsPush that can not tell which configs the proxy depends on until its scope is computed.
+	needsPush := func(proxy *model.Proxy, req *model.PushRequest) bool {
+		return proxy.SidecarScope != nil && DefaultProxyNeedsPush(proxy, req)
+	}
+	vsUpdate := func(name string) *model.PushRequest {
+		return &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: name, Namespace: "ns"}: {}},
+		}
+	}
+	scope := func(dependencies ...string) *model.SidecarScope {
+		sc := &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"}
+		for _, name := range dependencies {
+			sc.AddConfigDependencies(model.ConfigKey{Kind: gvk.VirtualService, Name: name, Namespace: "ns"})
+		}
+		return sc
+	}
+
+	t.Run("event before scope", func(t *testing.T) {
+		proxy := &model.Proxy{Type: model.SidecarProxy}
+		var skipped skippedPushes
+		req := vsUpdate("vs1")
+		if needsPush(proxy, req) {
+			t.Fatalf("expected the push to be skipped without a scope")
+		}
+		skipped.add(req)
+
+		proxy.SidecarScope = scope("vs1")
+		got := skipped.catchUp(proxy, needsPush)
+		if got == nil {
+			t.Fatalf("expected a catch-up push")
+		}
+		if !reflect.DeepEqual(got.ConfigsUpdated, req.ConfigsUpdated) {
+			t.Fatalf("expected catch-up of %v, got %v", req.ConfigsUpdated, got.ConfigsUpdated)
+		}
+		if again := skipped.catchUp(proxy, needsPush); again != nil {
+			t.Fatalf("expected skipped pushes to be cleared, got %v", again)
+		}
+	})
+
+	t.Run("event not applying to scope", func(t *testing.T) {
+		proxy := &model.Proxy{Type: model.SidecarProxy}
+		var skipped skippedPushes
+		skipped.add(vsUpdate("vs1"))
+		proxy.SidecarScope = scope("vs2")
+		if got := skipped.catchUp(proxy, needsPush); got != nil {
+			t.Fatalf("expected no catch-up push, got %v", got)
+		}
+	})
+
+	t.Run("oldest events evicted", func(t *testing.T) {
+		proxy := &model.Proxy{Type: model.SidecarProxy}
+		var skipped skippedPushes
+		var names []string
+		for i := 0; i < skippedPushCapacity+2; i++ {
+			names = append(names, fmt.Sprintf("vs%d", i))
+			skipped.add(vsUpdate(names[i]))
+		}
+		proxy.SidecarScope = scope(names...)
+		got := skipped.catchUp(proxy, needsPush)
+		want := map[model.ConfigKey]struct{}{}
+		for _, name := range names[2:] {
+			want[model.ConfigKey{Kind: gvk.VirtualService, Name: name, Namespace: "ns"}] = struct{}{}
+		}
+		if got == nil || !reflect.DeepEqual(got.ConfigsUpdated, want) {
+			t.Fatalf("expected catch-up of %v, got %v", want, got)
+		}
+	})
+}
+
+func destinationRule(namespace string, exportTo ...string) config.Config {
+	return config.Config{
+		Meta: config.Meta{Name: "dr", Namespace: namespace},
+		Spec: &networking.DestinationRule{Host: "svc.ns1.svc.cluster.local", ExportTo: exportTo},
+	}
+}
+
+func TestProxyNeedsPushClusterIDs(t *testing.T) {
+	proxy := func(clusterID string) *model.Proxy {
+		return &model.Proxy{
+			Type:         model.SidecarProxy,
+			Metadata:     &model.NodeMetadata{ClusterID: clusterID},
+			SidecarScope: &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+		}
+	}
+	cases := []struct {
+		name       string
+		clusterIDs map[string]struct{}
+		proxy      *model.Proxy
+		want       bool
+	}{
+		{"no constraint", nil, proxy("cluster-a"), true},
+		{"matching cluster", map[string]struct{}{"cluster-b": {}}, proxy("cluster-b"), true},
+		{"one of the clusters", map[string]struct{}{"cluster-a": {}, "cluster-b": {}}, proxy("cluster-a"), true},
+		{"other cluster", map[string]struct{}{"cluster-b": {}}, proxy("cluster-a"), false},
+		{"unknown cluster", map[string]struct{}{"cluster-b": {}}, proxy(""), true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "ns"}: {}},
+				ClusterIDs:     tt.clusterIDs,
+			}
+			if got := DefaultProxyNeedsPush(tt.proxy, req); got != tt.want {
+				t.Fatalf("DefaultProxyNeedsPush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyNeedsPushNamespaceSelectors(t *testing.T) {
+	proxy := func(namespaceLabels map[string]string) *model.Proxy {
+		return &model.Proxy{
+			Type:            model.SidecarProxy,
+			Metadata:        &model.NodeMetadata{Namespace: "ns"},
+			NamespaceLabels: namespaceLabels,
+			SidecarScope:    &model.SidecarScope{Name: "default", Namespace: "ns", RootNamespace: "istio-system"},
+		}
+	}
+	injected := labels.Instance{"istio-injection": "enabled"}
+	cases := []struct {
+		name      string
+		selectors labels.Collection
+		proxy     *model.Proxy
+		want      bool
+	}{
+		{"no selector", nil, proxy(map[string]string{"team": "a"}), true},
+		{"matching namespace", labels.Collection{injected}, proxy(map[string]string{"istio-injection": "enabled", "team": "a"}), true},
+		{"one of the selectors", labels.Collection{{"team": "b"}, injected}, proxy(map[string]string{"istio-injection": "enabled"}), true},
+		{"other namespace", labels.Collection{injected}, proxy(map[string]string{"istio-injection": "disabled"}), false},
+		{"namespace without labels", labels.Collection{injected}, proxy(map[string]string{}), false},
+		{"unknown namespace labels", labels.Collection{injected}, proxy(nil), true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:               true,
+				ConfigsUpdated:     map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "istio-system"}: {}},
+				NamespaceSelectors: tt.selectors,
+			}
+			if got := DefaultProxyNeedsPush(tt.proxy, req); got != tt.want {
+				t.Fatalf("DefaultProxyNeedsPush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyNeedsPushAddressFamilies(t *testing.T) {
+	instance := func(hostname, ip string) *model.ServiceInstance {
+		return &model.ServiceInstance{
+			Service:  &model.Service{Hostname: host.Name(hostname), Attributes: model.ServiceAttributes{Namespace: "ns"}},
+			Endpoint: &model.IstioEndpoint{Address: ip},
+		}
+	}
+	ipv6Only := &model.Proxy{
+		Type:             model.SidecarProxy,
+		IPAddresses:      []string{"2001:db8::1"},
+		ServiceInstances: []*model.ServiceInstance{instance("foo.ns.svc.cluster.local", "2001:db8::1")},
+	}
+	dualStack := &model.Proxy{
+		Type:        model.SidecarProxy,
+		IPAddresses: []string{"10.0.0.1", "2001:db8::1"},
+		ServiceInstances: []*model.ServiceInstance{
+			instance("foo.ns.svc.cluster.local", "10.0.0.1"),
+			instance("bar.ns.svc.cluster.local", "2001:db8::1"),
+		},
+	}
+	for _, proxy := range []*model.Proxy{ipv6Only, dualStack} {
+		proxy.SidecarScope = &model.SidecarScope{Name: "default", Namespace: "other", RootNamespace: "istio-system"}
+	}
+
+	cases := []struct {
+		name  string
+		proxy *model.Proxy
+		host  string
+		want  bool
+	}{
+		{"ipv6 only", ipv6Only, "foo.ns.svc.cluster.local", true},
+		{"dual stack first address", dualStack, "foo.ns.svc.cluster.local", true},
+		{"dual stack second address", dualStack, "bar.ns.svc.cluster.local", true},
+		{"unrelated service", dualStack, "baz.ns.svc.cluster.local", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.PushRequest{
+				Full:           true,
+				ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: tt.host, Namespace: "ns"}: {}},
+			}
+			if got := DefaultProxyNeedsPush(tt.proxy, req); got != tt.want {
+				t.Fatalf("DefaultProxyNeedsPush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, ip := range dualStack.IPAddresses {
+		if !hasIPAddress(dualStack, ip) {
+			t.Errorf("expected dual-stack proxy to have address %s", ip)
+		}
+	}
+	if hasIPAddress(dualStack, "2001:db8::2") {
+		t.Errorf("expected dual-stack proxy not to have address 2001:db8::2")
+	}
+}
+
+func TestRdsNeedsPushForTCPOnlySidecar(t *testing.T) {
+	push := model.NewPushContext()
+	push.Mesh = &meshconfig.MeshConfig{}
+	req := &model.PushRequest{
+		Full:           true,
+		Push:           push,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns"}: {}},
+	}
+	tcpOnly := &model.Proxy{Type: model.SidecarProxy, SidecarScope: &model.SidecarScope{}}
+	if rdsNeedsPush(req, tcpOnly) {
+		t.Fatalf("expected no RDS push for sidecar without HTTP services")
+	}
+	if !rdsNeedsPush(&model.PushRequest{Full: true, Push: push}, tcpOnly) {
+		t.Fatalf("expected RDS push for sidecar without HTTP services if configsUpdated is empty")
+	}
+
+	push.Mesh = &meshconfig.MeshConfig{ProxyHttpPort: 15080}
+	if !rdsNeedsPush(req, tcpOnly) {
+		t.Fatalf("expected RDS push for sidecar with HTTP proxy listener")
+	}
+
+	httpProxy := &model.Proxy{Type: model.SidecarProxy}
+	if !rdsNeedsPush(req, httpProxy) {
+		t.Fatalf("expected RDS push for sidecar that may have HTTP services")
+	}
+	if !rdsNeedsPush(req, &model.Proxy{Type: model.Router, SidecarScope: &model.SidecarScope{}}) {
+		t.Fatalf("expected RDS push for gateway")
+	}
+}
+
+func TestPushTypeOverride(t *testing.T) {
+	req := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "ns"}: {}},
+	}
+	all := map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.ListenerType: true, v3.RouteType: true}
+	sidecar := &model.Proxy{Type: model.SidecarProxy}
+	gateway := &model.Proxy{Type: model.Router}
+	if got := PushTypeFor(sidecar, req); !reflect.DeepEqual(got, all) {
+		t.Fatalf("got %v without override, want %v", got, all)
+	}
+
+	if err := SetPushTypeOverride(gvk.EnvoyFilter, map[string]bool{v3.ListenerType: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ClearPushTypeOverride(gvk.EnvoyFilter)
+	})
+	want := map[string]bool{v3.ListenerType: true}
+	for _, proxy := range []*model.Proxy{sidecar, gateway} {
+		if got := PushTypeFor(proxy, req); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v with override for %v, want %v", got, proxy.Type, want)
+		}
+	}
+
+	// Other kinds in the same request are still classified as usual.
+	mixed := &model.PushRequest{
+		Full: true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.EnvoyFilter, Name: "ef", Namespace: "ns"}:     {},
+			{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns"}: {},
+		},
+	}
+	want = map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.ListenerType: true, v3.RouteType: true}
+	if got := PushTypeFor(sidecar, mixed); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v for mixed request, want %v", got, want)
+	}
+
+	if err := SetPushTypeOverride(gvk.EnvoyFilter, map[string]bool{v3.ClusterType: true}); err == nil {
+		t.Fatalf("expected error for CDS override without EDS")
+	}
+	if err := SetPushTypeOverride(gvk.EnvoyFilter, map[string]bool{v3.SecretType: true}); err == nil {
+		t.Fatalf("expected error for SDS override")
+	}
+}
+
+func TestMinPushTypes(t *testing.T) {
+	sidecar := &model.Proxy{Type: model.SidecarProxy}
+	requestAuthn := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.RequestAuthentication, Name: "ra", Namespace: "ns"}: {}},
+	}
+	routeSettings := &model.PushRequest{
+		Full:                 true,
+		ConfigsUpdated:       map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns"}: {}},
+		VirtualServiceChange: model.VirtualServiceChangeRouteSettings,
+	}
+
+	// Without a floor, an override can drop LDS for RequestAuthentications, and the hint drops it for
+	// route settings changes of VirtualServices.
+	if err := SetPushTypeOverride(gvk.RequestAuthentication, map[string]bool{v3.RouteType: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ClearPushTypeOverride(gvk.RequestAuthentication)
+	})
+	if got := PushTypeFor(sidecar, requestAuthn); got[v3.ListenerType] {
+		t.Fatalf("got %v without floor, want LDS to be dropped", got)
+	}
+	if got := PushTypeFor(sidecar, routeSettings); got[v3.ListenerType] {
+		t.Fatalf("got %v without floor, want LDS to be dropped", got)
+	}
+
+	for _, kind := range []config.GroupVersionKind{gvk.RequestAuthentication, gvk.VirtualService} {
+		if err := SetMinPushTypes(kind, map[string]bool{v3.ListenerType: true}); err != nil {
+			t.Fatal(err)
+		}
+		kind := kind
+		t.Cleanup(func() {
+			ClearMinPushTypes(kind)
+		})
+	}
+	want := map[string]bool{v3.ListenerType: true, v3.RouteType: true}
+	if got := PushTypeFor(sidecar, requestAuthn); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v with floor, want %v", got, want)
+	}
+	want = map[string]bool{v3.ClusterType: true, v3.ListenerType: true, v3.RouteType: true}
+	if got := PushTypeFor(sidecar, routeSettings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v with floor, want %v", got, want)
+	}
+
+	// Incremental pushes only push endpoints, whatever the floor.
+	incremental := &model.PushRequest{ConfigsUpdated: requestAuthn.ConfigsUpdated}
+	if got := PushTypeFor(sidecar, incremental); got[v3.ListenerType] {
+		t.Fatalf("got %v for incremental push, want no LDS", got)
+	}
+
+	if err := SetMinPushTypes(gvk.RequestAuthentication, map[string]bool{v3.ClusterType: true}); err == nil {
+		t.Fatalf("expected error for CDS floor without EDS")
+	}
+	if err := SetMinPushTypes(gvk.RequestAuthentication, map[string]bool{v3.SecretType: true}); err == nil {
+		t.Fatalf("expected error for SDS floor")
+	}
+}
+
+func TestClassificationRulesVersions(t *testing.T) {
+	t.Cleanup(func() {
+		if err := SetClassificationRules(ClassificationRulesV1); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	sidecar := &model.Proxy{Type: model.SidecarProxy}
+	router := &model.Proxy{Type: model.Router}
+	full := func(kind config.GroupVersionKind) *model.PushRequest {
+		return &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: kind, Name: "name", Namespace: "ns"}: {}}}
+	}
+	routeSettings := full(gvk.VirtualService)
+	routeSettings.VirtualServiceChange = model.VirtualServiceChangeRouteSettings
+	incremental := full(gvk.ServiceEntry)
+	incremental.Full = false
+
+	cds, eds, lds, rds := v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType
+	cases := []struct {
+		name  string
+		proxy *model.Proxy
+		req   *model.PushRequest
+		v1    map[string]bool
+		v2    map[string]bool
+	}{
+		{"sidecar virtual service", sidecar, full(gvk.VirtualService),
+			map[string]bool{cds: true, lds: true, rds: true}, map[string]bool{cds: true, lds: true, rds: true}},
+		{"sidecar virtual service route settings", sidecar, routeSettings,
+			map[string]bool{cds: true, rds: true}, map[string]bool{rds: true}},
+		{"router virtual service", router, full(gvk.VirtualService),
+			map[string]bool{cds: true, lds: true, rds: true}, map[string]bool{cds: true, lds: true, rds: true}},
+		{"router virtual service route settings", router, routeSettings,
+			map[string]bool{cds: true, rds: true}, map[string]bool{rds: true}},
+		{"sidecar destination rule", sidecar, full(gvk.DestinationRule),
+			map[string]bool{cds: true, eds: true, rds: true}, map[string]bool{cds: true, eds: true, rds: true}},
+		{"sidecar service entry", sidecar, full(gvk.ServiceEntry),
+			map[string]bool{cds: true, eds: true, lds: true, rds: true}, map[string]bool{cds: true, eds: true, lds: true, rds: true}},
+		{"sidecar incremental service entry", sidecar, incremental,
+			map[string]bool{eds: true}, map[string]bool{eds: true}},
+		{"sidecar workload entry", sidecar, full(gvk.WorkloadEntry),
+			map[string]bool{eds: true, lds: true}, map[string]bool{eds: true, lds: true}},
+		{"sidecar authorization policy", sidecar, full(gvk.AuthorizationPolicy),
+			map[string]bool{lds: true}, map[string]bool{lds: true}},
+		{"sidecar peer authentication", sidecar, full(gvk.PeerAuthentication),
+			map[string]bool{cds: true, eds: true, lds: true}, map[string]bool{cds: true, eds: true, lds: true}},
+		{"sidecar gateway", sidecar, full(gvk.Gateway),
+			map[string]bool{}, map[string]bool{}},
+		{"router gateway", router, full(gvk.Gateway),
+			map[string]bool{cds: true, lds: true, rds: true}, map[string]bool{cds: true, lds: true, rds: true}},
+		{"router sidecar", router, full(gvk.Sidecar),
+			map[string]bool{}, map[string]bool{}},
+	}
+	check := func(version ClassificationRulesVersion) {
+		if err := SetClassificationRules(version); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range cases {
+			want := tt.v1
+			if version == ClassificationRulesV2 {
+				want = tt.v2
+			}
+			if got := PushTypeFor(tt.proxy, tt.req); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s types for %s = %v, want %v", version, tt.name, got, want)
+			}
+		}
+	}
+
+	// The default rules are v1.
+	for _, tt := range cases {
+		if got := PushTypeFor(tt.proxy, tt.req); !reflect.DeepEqual(got, tt.v1) {
+			t.Errorf("default types for %s = %v, want %v", tt.name, got, tt.v1)
+		}
+	}
+	check(ClassificationRulesV1)
+	check(ClassificationRulesV2)
+
+	// Beyond the cases above, v2 only differs from v1 by skipping CDS for VirtualService changes limited to
+	// route settings.
+	for _, proxy := range []*model.Proxy{sidecar, router} {
+		for _, schema := range collections.Pilot.All() {
+			kind := schema.Resource().GroupVersionKind()
+			for _, req := range []*model.PushRequest{full(kind), {ConfigsUpdated: full(kind).ConfigsUpdated}} {
+				if err := SetClassificationRules(ClassificationRulesV1); err != nil {
+					t.Fatal(err)
+				}
+				v1 := PushTypeFor(proxy, req)
+				if err := SetClassificationRules(ClassificationRulesV2); err != nil {
+					t.Fatal(err)
+				}
+				if v2 := PushTypeFor(proxy, req); !reflect.DeepEqual(v1, v2) {
+					t.Errorf("v2 types for %v %v = %v, want the v1 types %v", proxy.Type, kind, v2, v1)
+				}
+			}
+		}
+	}
+
+	if err := SetClassificationRules("v0"); err == nil {
+		t.Fatalf("expected error for unknown version")
+	}
+	// Switching back restores the v1 outputs.
+	check(ClassificationRulesV1)
+}
+
+func TestSecurityKindsPushTypes(t *testing.T) {
+	// The v1alpha1 authentication policies and RBAC kinds have already been removed from the schema,
+	// so only the v1beta1 security kinds are classified.
+	cases := []struct {
+		kind config.GroupVersionKind
+		want map[string]bool
+	}{
+		{gvk.PeerAuthentication, map[string]bool{v3.ClusterType: true, v3.EndpointType: true, v3.ListenerType: true}},
+		{gvk.RequestAuthentication, map[string]bool{v3.ListenerType: true}},
+		{gvk.AuthorizationPolicy, map[string]bool{v3.ListenerType: true}},
+	}
+	for _, tt := range cases {
+		for _, nodeType := range []model.NodeType{model.SidecarProxy, model.Router} {
+			t.Run(fmt.Sprintf("%s for %s", tt.kind.Kind, nodeType), func(t *testing.T) {
+				req := &model.PushRequest{
+					Full:           true,
+					ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: tt.kind, Name: "name", Namespace: "ns"}: {}},
+				}
+				if got := PushTypeFor(&model.Proxy{Type: nodeType}, req); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestAffectedClusters(t *testing.T) {
+	push := model.NewPushContext()
+	svc := &model.Service{
+		Hostname: "foo.com",
+		Ports: model.PortList{
+			{Name: "http", Port: 80, Protocol: protocol.HTTP},
+			{Name: "tcp", Port: 9000, Protocol: protocol.TCP},
+		},
+		Attributes: model.ServiceAttributes{Namespace: "ns"},
+	}
+	push.ServiceIndex.HostnameAndNamespace[svc.Hostname] = map[string]*model.Service{"ns": svc}
+	proxy := &model.Proxy{Type: model.SidecarProxy, SidecarScope: &model.SidecarScope{}}
+
+	cases := []struct {
+		name    string
+		configs map[model.ConfigKey]struct{}
+		want    map[string]struct{}
+		wantOk  bool
+	}{
+		{
+			name:    "service entry with two ports",
+			configs: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "foo.com", Namespace: "ns"}: {}},
+			want: map[string]struct{}{
+				"outbound|80||foo.com":   {},
+				"outbound|9000||foo.com": {},
+			},
+			wantOk: true,
+		},
+		{
+			name:    "deleted service entry",
+			configs: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "bar.com", Namespace: "ns"}: {}},
+		},
+		{
+			name: "not only service entries",
+			configs: map[model.ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: "foo.com", Namespace: "ns"}: {},
+				{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns"}:   {},
+			},
+		},
+		{
+			name: "empty configsUpdated",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := AffectedClusters(proxy, &model.PushRequest{Full: true, Push: push, ConfigsUpdated: tt.configs})
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOk)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got clusters %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigGenerationTrackerDropsStale(t *testing.T) {
+	vs := model.ConfigKey{Kind: gvk.VirtualService, Name: "vs", Namespace: "ns"}
+	dr := model.ConfigKey{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns"}
+	update := func(generations map[model.ConfigKey]int64, keys ...model.ConfigKey) *model.PushRequest {
+		req := &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{}, ConfigGenerations: generations}
+		for _, k := range keys {
+			req.ConfigsUpdated[k] = struct{}{}
+		}
+		return req
+	}
+
+	tracker := newConfigGenerationTracker()
+	if got := tracker.dropStale(update(map[model.ConfigKey]int64{vs: 2}, vs)); got == nil {
+		t.Fatalf("expected the newer generation to be kept")
+	}
+	if got := tracker.dropStale(update(map[model.ConfigKey]int64{vs: 1}, vs)); got != nil {
+		t.Fatalf("expected the older generation to be dropped, got %v", got.ConfigsUpdated)
+	}
+	if got := tracker.dropStale(update(map[model.ConfigKey]int64{vs: 2}, vs)); got == nil {
+		t.Fatalf("expected the same generation to be kept, as metadata changes do not increase it")
+	}
+
+	// Only the stale config is dropped from a request updating several.
+	req := update(map[model.ConfigKey]int64{vs: 1, dr: 1}, vs, dr)
+	got := tracker.dropStale(req)
+	if want := map[model.ConfigKey]struct{}{dr: {}}; got == nil || !reflect.DeepEqual(got.ConfigsUpdated, want) {
+		t.Fatalf("expected updates %v, got %v", want, got)
+	}
+	if len(req.ConfigsUpdated) != 2 {
+		t.Fatalf("expected the original request not to be modified, got %v", req.ConfigsUpdated)
+	}
+
+	// Deletions do not carry a generation, and a config created again starts over.
+	if got := tracker.dropStale(update(nil, vs)); got == nil {
+		t.Fatalf("expected the deletion to be kept")
+	}
+	if got := tracker.dropStale(update(map[model.ConfigKey]int64{vs: 1}, vs)); got == nil {
+		t.Fatalf("expected the first generation of the new config to be kept")
+	}
+
+	// Requests without updated configs push everything and are never dropped.
+	if got := tracker.dropStale(&model.PushRequest{Full: true}); got == nil {
+		t.Fatalf("expected the request without updated configs to be kept")
+	}
+}
+
+func TestProxyPushAccounting(t *testing.T) {
+	a := newProxyPushAccounting()
+	now := time.Now()
+	for i := 4; i >= 0; i-- {
+		a.record("hot", now.Add(-time.Duration(i)*time.Second))
+	}
+	a.record("cold", now.Add(-time.Second))
+	// Pushes outside of the window are not counted.
+	for i := 0; i < 5; i++ {
+		a.record("stale", now.Add(-time.Minute))
+	}
+
+	if got, want := a.hotProxies(3, 10*time.Second, now), []string{"hot"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got hot proxies %v, want %v", got, want)
+	}
+	if got, want := a.hotProxies(1, 10*time.Second, now), []string{"cold", "hot"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got hot proxies %v, want %v", got, want)
+	}
+	if got, want := a.hotProxies(5, 2*time.Minute, now), []string{"hot", "stale"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got hot proxies %v, want %v", got, want)
+	}
+
+	a.remove("hot")
+	if got := a.hotProxies(3, 10*time.Second, now); len(got) != 0 {
+		t.Fatalf("expected no hot proxies after removal, got %v", got)
+	}
+
+	// Pushes older than the retention are dropped on the next record.
+	a.record("cold", now.Add(pushAccountingRetention+time.Second))
+	if got := len(a.pushes["cold"]); got != 1 {
+		t.Fatalf("expected expired pushes to be dropped, got %d pushes", got)
+	}
+}
+
+func BenchmarkListEquals(b *testing.B) {
+	size := 100
+	var l []string
+	for i := 0; i < size; i++ {
+		l = append(l, strconv.Itoa(i))
+	}
+	var equal []string
+	for i := 0; i < size; i++ {
+		equal = append(equal, strconv.Itoa(i))
+	}
+	var notEqual []string
+	for i := 0; i < size; i++ {
+		notEqual = append(notEqual, strconv.Itoa(i))
+	}
+	notEqual[size-1] = "z"
+
+	for n := 0; n < b.N; n++ {
+		listEqualUnordered(l, equal)
+		listEqualUnordered(l, notEqual)
+	}
+}
+
+const (
+	benchmarkNamespaces        = 50
+	benchmarkServicesPerNs     = 20
+	benchmarkRootNamespace     = "istio-system"
+	benchmarkRouterPercent     = 5
+	benchmarkImportedNamespace = 3
+)
+
+func benchmarkNamespace(i int) string {
+	return "ns-" + strconv.Itoa(i%benchmarkNamespaces)
+}
+
+// newBenchmarkSidecarScope builds a scope depending on the services, virtual services and destination rules
+// of its own namespace and a few of the following ones, similar to a Sidecar importing some namespaces.
+func newBenchmarkSidecarScope(ns int) *model.SidecarScope {
+	sc := &model.SidecarScope{Name: "default", Namespace: benchmarkNamespace(ns), RootNamespace: benchmarkRootNamespace}
+	for i := 0; i <= benchmarkImportedNamespace; i++ {
+		namespace := benchmarkNamespace(ns + i)
+		for j := 0; j < benchmarkServicesPerNs; j++ {
+			name := "svc-" + strconv.Itoa(j)
+			sc.AddConfigDependencies(
+				model.ConfigKey{Kind: gvk.ServiceEntry, Name: name + "." + namespace + ".svc.cluster.local", Namespace: namespace},
+				model.ConfigKey{Kind: gvk.VirtualService, Name: name, Namespace: namespace},
+				model.ConfigKey{Kind: gvk.DestinationRule, Name: name, Namespace: namespace},
+			)
+		}
+	}
+	return sc
+}
+
+// newBenchmarkProxies builds n proxies spread over the benchmark namespaces, with a share of them routers.
+func newBenchmarkProxies(n int) []*model.Proxy {
+	scopes := make([]*model.SidecarScope, benchmarkNamespaces)
+	for i := range scopes {
+		scopes[i] = newBenchmarkSidecarScope(i)
+	}
+	proxies := make([]*model.Proxy, 0, n)
+	for i := 0; i < n; i++ {
+		proxy := &model.Proxy{
+			ID:              "proxy-" + strconv.Itoa(i),
+			Type:            model.SidecarProxy,
+			IPAddresses:     []string{"10.0.0.1"},
+			ConfigNamespace: benchmarkNamespace(i),
+			Metadata:        &model.NodeMetadata{},
+			SidecarScope:    scopes[i%benchmarkNamespaces],
+		}
+		if i%100 < benchmarkRouterPercent {
+			proxy.Type = model.Router
+			proxy.SidecarScope = &model.SidecarScope{Name: "default", Namespace: proxy.ConfigNamespace, RootNamespace: benchmarkRootNamespace}
+			proxy.SidecarScope.AddConfigDependencies(model.ConfigKey{Kind: gvk.VirtualService, Name: "svc-0", Namespace: proxy.ConfigNamespace})
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+// newBenchmarkPushRequest builds a full push request with a mix of config kinds, as typically batched by debouncing.
+func newBenchmarkPushRequest() *model.PushRequest {
+	return &model.PushRequest{
+		Full: true,
+		Push: &model.PushContext{Mesh: &meshconfig.MeshConfig{}},
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "svc-1." + benchmarkNamespace(3) + ".svc.cluster.local", Namespace: benchmarkNamespace(3)}: {},
+			{Kind: gvk.VirtualService, Name: "svc-2", Namespace: benchmarkNamespace(7)}:                                               {},
+			{Kind: gvk.DestinationRule, Name: "svc-3", Namespace: benchmarkNamespace(1)}:                                              {},
+			{Kind: gvk.AuthorizationPolicy, Name: "policy", Namespace: benchmarkNamespace(5)}:                                         {},
+		},
+	}
+}
+
+func BenchmarkProxyNeedsPush(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			proxies := newBenchmarkProxies(n)
+			req := newBenchmarkPushRequest()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, proxy := range proxies {
+					EvaluatePush(proxy, req)
+				}
+			}
+		})
 	}
 }
 
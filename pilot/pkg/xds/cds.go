@@ -17,6 +17,7 @@ package xds
 import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
@@ -55,14 +56,34 @@ func cdsNeedsPush(req *model.PushRequest, proxy *model.Proxy) bool {
 	if len(req.ConfigsUpdated) == 0 {
 		return true
 	}
+	if minPushTypeRequired(req, v3.ClusterType) {
+		return true
+	}
+	if statsOnlyChange(req) {
+		return false
+	}
+	rules := activeClassificationRules()
 	for config := range req.ConfigsUpdated {
+		if overridden, push := pushTypeOverride(config.Kind, v3.ClusterType); overridden {
+			if push {
+				return true
+			}
+			continue
+		}
+		if config.Kind == gvk.Gateway && !gatewayChangeAffects(req, v3.ClusterType) {
+			continue
+		}
+		if config.Kind == gvk.VirtualService && req.VirtualServiceChange == model.VirtualServiceChangeRouteSettings &&
+			rules.routeSettingsSkipClusters {
+			continue
+		}
 		if proxy.Type == model.Router {
 			if _, f := pushCdsGatewayConfig[config.Kind]; f {
 				return true
 			}
 		}
 
-		if _, f := skippedCdsConfigs[config.Kind]; !f {
+		if !rules.skipped(v3.ClusterType, config.Kind) {
 			return true
 		}
 	}
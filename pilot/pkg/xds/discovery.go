@@ -107,6 +107,10 @@ type DiscoveryServer struct {
 	// incremental updates. This is keyed by service and namespace
 	EndpointShardsByService map[string]map[string]*EndpointShards
 
+	// drainingServices are the services whose last endpoint shard was removed by a service deletion, and
+	// whose next update is flagged as draining. Protected by mutex.
+	drainingServices map[model.ConfigKey]struct{}
+
 	pushChannel chan *model.PushRequest
 
 	// mutex used for config update scheduling (former cache update mutex)
@@ -140,6 +144,12 @@ type DiscoveryServer struct {
 
 	// Cache for XDS resources
 	Cache model.XdsCache
+
+	// pushAccounting tracks recent pushes per proxy, to detect proxies receiving too many pushes.
+	pushAccounting *proxyPushAccounting
+
+	// configGenerations tracks the newest generation seen for each config, to drop stale updates.
+	configGenerations *configGenerationTracker
 }
 
 // EndpointShards holds the set of endpoint shards of a service. Registries update
@@ -169,6 +179,7 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 		Generators:              map[string]model.XdsResourceGenerator{},
 		ProxyNeedsPush:          DefaultProxyNeedsPush,
 		EndpointShardsByService: map[string]map[string]*EndpointShards{},
+		drainingServices:        map[model.ConfigKey]struct{}{},
 		concurrentPushLimit:     make(chan struct{}, features.PushThrottle),
 		InboundUpdates:          atomic.NewInt64(0),
 		CommittedUpdates:        atomic.NewInt64(0),
@@ -181,8 +192,10 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 			debounceMax:       features.DebounceMax,
 			enableEDSDebounce: features.EnableEDSDebounce.Get(),
 		},
-		Cache:      model.DisabledCache{},
-		instanceID: instanceID,
+		Cache:             model.DisabledCache{},
+		instanceID:        instanceID,
+		pushAccounting:    newProxyPushAccounting(),
+		configGenerations: newConfigGenerationTracker(),
 	}
 
 	// Flush cached discovery responses when detecting jwt public key change.
@@ -327,8 +340,22 @@ func (s *DiscoveryServer) globalPushContext() *model.PushContext {
 // It replaces the 'clear cache' from v1.
 func (s *DiscoveryServer) ConfigUpdate(req *model.PushRequest) {
 	inboundConfigUpdates.Increment()
+	if req = s.configGenerations.dropStale(req); req == nil {
+		return
+	}
+	req = s.markDrainingServices(req)
+	if req.CorrelationID == "" {
+		// Identify the change, so its push decisions can be traced in the logs.
+		withID := *req
+		req = withID.SetCorrelationID(uuid.New().String())
+	}
+	if !s.IsServerReady() {
+		// The config stores replay all existing resources as updates until the caches are synced. Push
+		// everything for them, so they are debounced into a single full push rather than evaluated one by one.
+		req = pushEverything(req)
+	}
 	s.InboundUpdates.Inc()
-	s.pushChannel <- req
+	s.pushChannel <- applyClassificationProfile(req)
 }
 
 // Debouncing and push request happens in a separate thread, it uses locks
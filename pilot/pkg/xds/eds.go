@@ -16,6 +16,7 @@ package xds
 
 import (
 	"fmt"
+	"strconv"
 
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -29,6 +30,7 @@ import (
 	"istio.io/istio/pilot/pkg/util/sets"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -93,17 +95,28 @@ func (s *DiscoveryServer) EDSUpdate(clusterID, serviceName string, namespace str
 	istioEndpoints []*model.IstioEndpoint) {
 	inboundEDSUpdates.Increment()
 	// Update the endpoint shards
-	fp := s.edsCacheUpdate(clusterID, serviceName, namespace, istioEndpoints)
+	fp, delta := s.edsCacheUpdate(clusterID, serviceName, namespace, istioEndpoints)
+	key := model.ConfigKey{
+		Kind:      gvk.ServiceEntry,
+		Name:      serviceName,
+		Namespace: namespace,
+	}
+	req := &model.PushRequest{
+		Full:           fp,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{key: {}},
+		Reason:         []model.TriggerReason{model.EndpointUpdate},
+	}
+	// A full push recomputes all endpoints anyway.
+	if !fp {
+		req.EndpointDeltas = map[model.ConfigKey]*model.EndpointDelta{key: delta}
+		// The endpoints of cluster-local services are only sent to proxies in the same cluster. Registries
+		// without a cluster, such as ServiceEntries, apply to proxies in all clusters.
+		if push := s.globalPushContext(); clusterID != "" && push != nil && push.IsClusterLocalHost(host.Name(serviceName)) {
+			req.ClusterIDs = map[string]struct{}{clusterID: {}}
+		}
+	}
 	// Trigger a push
-	s.ConfigUpdate(&model.PushRequest{
-		Full: fp,
-		ConfigsUpdated: map[model.ConfigKey]struct{}{{
-			Kind:      gvk.ServiceEntry,
-			Name:      serviceName,
-			Namespace: namespace,
-		}: {}},
-		Reason: []model.TriggerReason{model.EndpointUpdate},
-	})
+	s.ConfigUpdate(req)
 }
 
 // EDSCacheUpdate computes destination address membership across all clusters and networks.
@@ -122,17 +135,17 @@ func (s *DiscoveryServer) EDSCacheUpdate(clusterID, serviceName string, namespac
 
 // edsCacheUpdate updates EndpointShards data by clusterID, hostname, IstioEndpoints.
 // It also tracks the changes to ServiceAccounts. It returns whether a full push
-// is needed or incremental push is sufficient.
+// is needed or incremental push is sufficient, and the change to the endpoints of the shard.
 func (s *DiscoveryServer) edsCacheUpdate(clusterID, hostname string, namespace string,
-	istioEndpoints []*model.IstioEndpoint) bool {
+	istioEndpoints []*model.IstioEndpoint) (bool, *model.EndpointDelta) {
 	if len(istioEndpoints) == 0 {
 		// Should delete the service EndpointShards when endpoints become zero to prevent memory leak,
 		// but we should not do not delete the keys from EndpointShardsByService map - that will trigger
 		// unnecessary full push which can become a real problem if a pod is in crashloop and thus endpoints
 		// flip flopping between 1 and 0.
-		s.deleteEndpointShards(clusterID, hostname, namespace)
+		removed := s.deleteEndpointShards(clusterID, hostname, namespace)
 		adsLog.Infof("Incremental push, service %s has no endpoints", hostname)
-		return false
+		return false, endpointDelta(clusterID, removed, nil)
 	}
 
 	fullPush := false
@@ -161,11 +174,51 @@ func (s *DiscoveryServer) edsCacheUpdate(clusterID, hostname string, namespace s
 		adsLog.Infof("Full push, service accounts changed, %v", hostname)
 		fullPush = true
 	}
+	delta := endpointDelta(clusterID, ep.Shards[clusterID], istioEndpoints)
 	ep.Shards[clusterID] = istioEndpoints
 	ep.ServiceAccounts = serviceAccounts
 	ep.mutex.Unlock()
 
-	return fullPush
+	return fullPush, delta
+}
+
+// endpointDelta returns the change from the old to the new endpoints of a shard. Endpoints are identified by
+// their address and port, endpoints whose attributes changed are both removed and added.
+func endpointDelta(clusterID string, old, updated []*model.IstioEndpoint) *model.EndpointDelta {
+	delta := &model.EndpointDelta{ClusterID: clusterID}
+	previous := make(map[string]*model.IstioEndpoint, len(old))
+	for _, e := range old {
+		previous[endpointID(e)] = e
+	}
+	current := make(map[string]struct{}, len(updated))
+	for _, e := range updated {
+		id := endpointID(e)
+		current[id] = struct{}{}
+		if prev, f := previous[id]; !f {
+			delta.Added = append(delta.Added, e)
+		} else if !sameEndpoint(prev, e) {
+			delta.Removed = append(delta.Removed, prev)
+			delta.Added = append(delta.Added, e)
+		}
+	}
+	for _, e := range old {
+		if _, f := current[endpointID(e)]; !f {
+			delta.Removed = append(delta.Removed, e)
+		}
+	}
+	return delta
+}
+
+func endpointID(e *model.IstioEndpoint) string {
+	return e.Address + ":" + strconv.Itoa(int(e.EndpointPort)) + "/" + e.ServicePortName
+}
+
+// sameEndpoint returns whether endpoints with the same ID have the same attributes, ignoring the cached Envoy
+// endpoint.
+func sameEndpoint(a, b *model.IstioEndpoint) bool {
+	return a.Labels.Equals(b.Labels) && a.ServiceAccount == b.ServiceAccount && a.Network == b.Network &&
+		a.Locality == b.Locality && a.LbWeight == b.LbWeight && a.TLSMode == b.TLSMode &&
+		a.Namespace == b.Namespace && a.WorkloadName == b.WorkloadName && a.TunnelAbility == b.TunnelAbility
 }
 
 func (s *DiscoveryServer) getOrCreateEndpointShard(serviceName, namespace string) (*EndpointShards, bool) {
@@ -179,6 +232,7 @@ func (s *DiscoveryServer) getOrCreateEndpointShard(serviceName, namespace string
 		return ep, false
 	}
 	// This endpoint is for a service that was not previously loaded.
+	delete(s.drainingServices, model.ConfigKey{Kind: gvk.ServiceEntry, Name: serviceName, Namespace: namespace})
 	ep := &EndpointShards{
 		Shards:          map[string][]*model.IstioEndpoint{},
 		ServiceAccounts: sets.Set{},
@@ -189,16 +243,19 @@ func (s *DiscoveryServer) getOrCreateEndpointShard(serviceName, namespace string
 }
 
 // deleteEndpointShards deletes matching endpoint shards from EndpointShardsByService map. This is called when
-// endpoints are deleted.
-func (s *DiscoveryServer) deleteEndpointShards(cluster, serviceName, namespace string) {
+// endpoints are deleted. It returns the endpoints of the deleted shard.
+func (s *DiscoveryServer) deleteEndpointShards(cluster, serviceName, namespace string) []*model.IstioEndpoint {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	var removed []*model.IstioEndpoint
 	if s.EndpointShardsByService[serviceName] != nil &&
 		s.EndpointShardsByService[serviceName][namespace] != nil {
 		s.EndpointShardsByService[serviceName][namespace].mutex.Lock()
+		removed = s.EndpointShardsByService[serviceName][namespace].Shards[cluster]
 		delete(s.EndpointShardsByService[serviceName][namespace].Shards, cluster)
 		s.EndpointShardsByService[serviceName][namespace].mutex.Unlock()
 	}
+	return removed
 }
 
 // deleteService deletes all service related references from EndpointShardsByService. This is called
@@ -217,6 +274,7 @@ func (s *DiscoveryServer) deleteService(cluster, serviceName, namespace string)
 
 		if shards == 0 {
 			delete(s.EndpointShardsByService[serviceName], namespace)
+			s.drainingServices[model.ConfigKey{Kind: gvk.ServiceEntry, Name: serviceName, Namespace: namespace}] = struct{}{}
 		}
 		if len(s.EndpointShardsByService[serviceName]) == 0 {
 			delete(s.EndpointShardsByService, serviceName)
@@ -224,6 +282,37 @@ func (s *DiscoveryServer) deleteService(cluster, serviceName, namespace string)
 	}
 }
 
+// markDrainingServices returns the push request with the updated services whose last endpoint shard was removed
+// by a service deletion flagged as draining. Each deletion is only flagged once. The request itself is not
+// modified.
+func (s *DiscoveryServer) markDrainingServices(req *model.PushRequest) *model.PushRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.drainingServices) == 0 {
+		return req
+	}
+	var draining map[model.ConfigKey]struct{}
+	for conf := range req.ConfigsUpdated {
+		if _, f := s.drainingServices[conf]; !f {
+			continue
+		}
+		delete(s.drainingServices, conf)
+		if draining == nil {
+			draining = make(map[model.ConfigKey]struct{}, len(req.DrainingServices)+1)
+			for c := range req.DrainingServices {
+				draining[c] = struct{}{}
+			}
+		}
+		draining[conf] = struct{}{}
+	}
+	if draining == nil {
+		return req
+	}
+	marked := *req
+	marked.DrainingServices = draining
+	return &marked
+}
+
 // llbEndpointAndOptionsForCluster return the endpoints for a cluster
 // Initial implementation is computing the endpoints on the flight - caching will be added as needed, based on
 // perf tests.
@@ -317,21 +406,112 @@ func edsNeedsPush(updates model.XdsUpdates) bool {
 	if len(updates) == 0 {
 		return true
 	}
+	rules := activeClassificationRules()
 	for config := range updates {
-		if _, f := skippedEdsConfigs[config.Kind]; !f {
+		if overridden, push := pushTypeOverride(config.Kind, v3.EndpointType); overridden {
+			if push {
+				return true
+			}
+			continue
+		}
+		if !rules.skipped(v3.EndpointType, config.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// edsRequestNeedsPush returns whether the push request may change any endpoints. Updates of draining services
+// are ignored, as their clusters are about to be removed, and so are updates known not to change any endpoint.
+func edsRequestNeedsPush(req *model.PushRequest) bool {
+	if req.Full && minPushTypeRequired(req, v3.EndpointType) {
+		return true
+	}
+	if statsOnlyChange(req) {
+		return false
+	}
+	if (len(req.DrainingServices) == 0 && len(req.EndpointDeltas) == 0) || len(req.ConfigsUpdated) == 0 {
+		return edsNeedsPush(req.ConfigsUpdated)
+	}
+	updates := make(model.XdsUpdates, len(req.ConfigsUpdated))
+	for conf := range req.ConfigsUpdated {
+		if _, f := req.DrainingServices[conf]; !f && !endpointsUnchanged(req, conf) {
+			updates[conf] = struct{}{}
+		}
+	}
+	return len(updates) > 0 && edsNeedsPush(updates)
+}
+
+// endpointsUnchanged returns whether the endpoint delta of the updated service is known to be empty.
+func endpointsUnchanged(req *model.PushRequest, conf model.ConfigKey) bool {
+	delta, f := EndpointDeltaFor(req, conf)
+	return f && len(delta.Added) == 0 && len(delta.Removed) == 0
+}
+
+// unchangedHosts returns the hostnames of the services whose endpoints the push request does not change. A
+// hostname declared by ServiceEntries in several namespaces is only unchanged if none of its updated
+// ServiceEntries changed any endpoint.
+func unchangedHosts(req *model.PushRequest) map[string]struct{} {
+	if len(req.EndpointDeltas) == 0 {
+		return nil
+	}
+	hosts := map[string]struct{}{}
+	for conf := range req.ConfigsUpdated {
+		if conf.Kind == gvk.ServiceEntry && endpointsUnchanged(req, conf) {
+			hosts[conf.Name] = struct{}{}
+		}
+	}
+	for conf := range req.ConfigsUpdated {
+		if conf.Kind == gvk.ServiceEntry && !endpointsUnchanged(req, conf) {
+			delete(hosts, conf.Name)
+		}
+	}
+	return hosts
+}
+
+// drainedCluster returns whether the push request drains the service of the cluster built by the endpoint
+// builder. A hostname may be declared by ServiceEntries in several namespaces, so the cluster is only drained if
+// the service it resolves to is draining, or if it no longer resolves to any service after a service of its
+// hostname was drained.
+func drainedCluster(req *model.PushRequest, b EndpointBuilder) bool {
+	if len(req.DrainingServices) == 0 {
+		return false
+	}
+	if b.service != nil {
+		_, f := req.DrainingServices[model.ConfigKey{
+			Kind:      gvk.ServiceEntry,
+			Name:      string(b.hostname),
+			Namespace: b.service.Attributes.Namespace,
+		}]
+		return f
+	}
+	for conf := range req.DrainingServices {
+		if conf.Kind == gvk.ServiceEntry && conf.Name == string(b.hostname) {
 			return true
 		}
 	}
 	return false
 }
 
+// EdsUpdatedHosts returns the unique hostnames whose endpoints must be recomputed for an incremental push
+// request. ServiceEntries in different namespaces may declare the same host, and the endpoints of their clusters
+// are keyed by hostname, so each host is only recomputed once however many of them changed. Hosts whose
+// endpoints did not change are left out.
+func EdsUpdatedHosts(req *model.PushRequest) map[string]struct{} {
+	hosts := model.ConfigNamesOfKind(req.ConfigsUpdated, gvk.ServiceEntry)
+	for h := range unchangedHosts(req) {
+		delete(hosts, h)
+	}
+	return hosts
+}
+
 func (eds *EdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, req *model.PushRequest) (model.Resources, error) {
-	if !edsNeedsPush(req.ConfigsUpdated) {
+	if !edsRequestNeedsPush(req) {
 		return nil, nil
 	}
 	var edsUpdatedServices map[string]struct{}
 	if !req.Full {
-		edsUpdatedServices = model.ConfigNamesOfKind(req.ConfigsUpdated, gvk.ServiceEntry)
+		edsUpdatedServices = EdsUpdatedHosts(req)
 	}
 	resources := make([]*any.Any, 0)
 	empty := 0
@@ -339,8 +519,8 @@ func (eds *EdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 	cached := 0
 	regenerated := 0
 	for _, clusterName := range w.ResourceNames {
+		_, _, hostname, _ := model.ParseSubsetKey(clusterName)
 		if edsUpdatedServices != nil {
-			_, _, hostname, _ := model.ParseSubsetKey(clusterName)
 			if _, ok := edsUpdatedServices[string(hostname)]; !ok {
 				// Cluster was not updated, skip recomputing. This happens when we get an incremental update for a
 				// specific Hostname. On connect or for full push edsUpdatedServices will be empty.
@@ -348,6 +528,10 @@ func (eds *EdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 			}
 		}
 		builder := NewEndpointBuilder(clusterName, proxy, push)
+		if drainedCluster(req, builder) {
+			// The cluster is being removed, its endpoints are not worth recomputing.
+			continue
+		}
 		if marshalledEndpoint, f := eds.Server.Cache.Get(builder); f {
 			resources = append(resources, marshalledEndpoint)
 			cached++
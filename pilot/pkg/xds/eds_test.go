@@ -116,6 +116,24 @@ func TestIncrementalPush(t *testing.T) {
 	})
 }
 
+func TestEdsUpdatedHosts(t *testing.T) {
+	unchanged := model.ConfigKey{Kind: gvk.ServiceEntry, Name: "unchanged.com", Namespace: "ns1"}
+	req := &model.PushRequest{
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: "foo.com", Namespace: "ns1"}: {},
+			{Kind: gvk.ServiceEntry, Name: "foo.com", Namespace: "ns2"}: {},
+			{Kind: gvk.ServiceEntry, Name: "bar.com", Namespace: "ns1"}: {},
+			{Kind: gvk.DestinationRule, Name: "dr", Namespace: "ns1"}:   {},
+			unchanged: {},
+		},
+		EndpointDeltas: map[model.ConfigKey]*model.EndpointDelta{unchanged: {}},
+	}
+	want := map[string]struct{}{"foo.com": {}, "bar.com": {}}
+	if got := xds.EdsUpdatedHosts(req); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected hosts %v, got %v", want, got)
+	}
+}
+
 func TestEds(t *testing.T) {
 	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
 		ConfigString: mustReadFile(t, "tests/testdata/config/destination-rule-locality.yaml"),
@@ -17,6 +17,7 @@ package xds
 import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
@@ -46,8 +47,24 @@ func ldsNeedsPush(req *model.PushRequest) bool {
 	if len(req.ConfigsUpdated) == 0 {
 		return true
 	}
+	if minPushTypeRequired(req, v3.ListenerType) {
+		return true
+	}
+	rules := activeClassificationRules()
 	for config := range req.ConfigsUpdated {
-		if _, f := skippedLdsConfigs[config.Kind]; !f {
+		if overridden, push := pushTypeOverride(config.Kind, v3.ListenerType); overridden {
+			if push {
+				return true
+			}
+			continue
+		}
+		if config.Kind == gvk.Gateway && !gatewayChangeAffects(req, v3.ListenerType) {
+			continue
+		}
+		if config.Kind == gvk.VirtualService && req.VirtualServiceChange == model.VirtualServiceChangeRouteSettings {
+			continue
+		}
+		if !rules.skipped(v3.ListenerType, config.Kind) {
 			return true
 		}
 	}
@@ -167,6 +167,23 @@ var (
 		monitoring.WithLabels(typeTag),
 	)
 
+	invalidConfigKindUpdates = monitoring.NewSum(
+		"pilot_xds_invalid_config_kind_updates",
+		"Total number of updated configs without a kind seen when deciding which proxies to push.",
+	)
+
+	retiredConfigKindUpdates = monitoring.NewSum(
+		"pilot_xds_retired_config_kind_updates",
+		"Total number of updated configs of kinds retired from the schema seen when deciding which proxies to push.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	zeroTargetPushes = monitoring.NewSum(
+		"pilot_xds_zero_target_pushes",
+		"Total number of pushes reaching none of the connected proxies, labeled by the kind of most of their updated configs.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	inboundConfigUpdates  = inboundUpdates.With(typeTag.Value("config"))
 	inboundEDSUpdates     = inboundUpdates.With(typeTag.Value("eds"))
 	inboundServiceUpdates = inboundUpdates.With(typeTag.Value("svc"))
@@ -256,5 +273,8 @@ func init() {
 		sendTime,
 		totalDelayedPushes,
 		totalDelayedPushTimeouts,
+		invalidConfigKindUpdates,
+		retiredConfigKindUpdates,
+		zeroTargetPushes,
 	)
 }
@@ -17,6 +17,7 @@ package xds
 import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
@@ -37,7 +38,20 @@ var skippedRdsConfigs = map[config.GroupVersionKind]struct{}{
 	gvk.Secret:                {},
 }
 
-func rdsNeedsPush(req *model.PushRequest) bool {
+// proxyHasHTTPRoutes returns whether the proxy may have any outbound HTTP route configurations.
+// Sidecars that only import TCP services have none, so pushing RDS to them is wasted.
+func proxyHasHTTPRoutes(proxy *model.Proxy, push *model.PushContext) bool {
+	if proxy == nil || proxy.Type != model.SidecarProxy {
+		return true
+	}
+	if push != nil && push.Mesh != nil && push.Mesh.ProxyHttpPort != 0 {
+		// The HTTP proxy listener uses RDS.
+		return true
+	}
+	return proxy.SidecarScope.HasHTTPServices()
+}
+
+func rdsNeedsPush(req *model.PushRequest, proxy *model.Proxy) bool {
 	if req == nil {
 		return true
 	}
@@ -49,8 +63,30 @@ func rdsNeedsPush(req *model.PushRequest) bool {
 	if len(req.ConfigsUpdated) == 0 {
 		return true
 	}
+	if minPushTypeRequired(req, v3.RouteType) {
+		return true
+	}
+	if statsOnlyChange(req) {
+		return false
+	}
+	if !proxyHasHTTPRoutes(proxy, req.Push) {
+		return false
+	}
+	rules := activeClassificationRules()
 	for config := range req.ConfigsUpdated {
-		if _, f := skippedRdsConfigs[config.Kind]; !f {
+		if overridden, push := pushTypeOverride(config.Kind, v3.RouteType); overridden {
+			if push {
+				return true
+			}
+			continue
+		}
+		if config.Kind == gvk.Gateway && !gatewayChangeAffects(req, v3.RouteType) {
+			continue
+		}
+		if config.Kind == gvk.DestinationRule && req.DestinationRuleChange == model.DestinationRuleChangeTrafficPolicy {
+			continue
+		}
+		if !rules.skipped(v3.RouteType, config.Kind) {
 			return true
 		}
 	}
@@ -58,7 +94,7 @@ func rdsNeedsPush(req *model.PushRequest) bool {
 }
 
 func (c RdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, req *model.PushRequest) (model.Resources, error) {
-	if !rdsNeedsPush(req) {
+	if !rdsNeedsPush(req, proxy) {
 		return nil, nil
 	}
 	rawRoutes := c.Server.ConfigGenerator.BuildHTTPRoutes(proxy, push, w.ResourceNames)
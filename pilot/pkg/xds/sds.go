@@ -72,14 +72,18 @@ func parseResourceName(resource, defaultNamespace string) (SecretResource, error
 	return SecretResource{}, fmt.Errorf("unknown resource type: %v", resource)
 }
 
-func needsUpdate(proxy *model.Proxy, updates model.XdsUpdates) bool {
+func needsUpdate(proxy *model.Proxy, req *model.PushRequest) bool {
 	if proxy.Type != model.Router {
 		return false
 	}
-	if len(updates) == 0 {
+	if len(req.ConfigsUpdated) == 0 {
 		return true
 	}
-	if len(model.ConfigNamesOfKind(updates, gvk.Secret)) > 0 {
+	if len(model.ConfigNamesOfKind(req.ConfigsUpdated, gvk.Secret)) > 0 {
+		return true
+	}
+	// TLS changes of a gateway may reference different credentials
+	if req.GatewayChange == model.GatewayChangeTLS && len(model.ConfigNamesOfKind(req.ConfigsUpdated, gvk.Gateway)) > 0 {
 		return true
 	}
 	return false
@@ -108,7 +112,7 @@ func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *mod
 		adsLog.Warnf("proxy %v is not authorized to receive secrets: %v", proxy.ID, err)
 		return nil, nil
 	}
-	if req == nil || !needsUpdate(proxy, req.ConfigsUpdated) {
+	if req == nil || !needsUpdate(proxy, req) {
 		return nil, nil
 	}
 	var updatedSecrets map[model.ConfigKey]struct{}